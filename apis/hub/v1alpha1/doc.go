@@ -0,0 +1,22 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 contains API Schema definitions for the hub v1alpha1 API group.
+// These types are served as CRDs on the OCM hub cluster and used by the license-proxyserver manager.
+
+// +k8s:deepcopy-gen=package
+// +groupName=hub.licenses.appscode.com
+package v1alpha1 // import "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
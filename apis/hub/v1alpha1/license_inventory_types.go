@@ -0,0 +1,124 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	licenseapi "go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ResourceKindLicenseInventory = "LicenseInventory"
+	ResourceLicenseInventory     = "licenseinventory"
+	ResourceLicenseInventories   = "licenseinventories"
+)
+
+const (
+	// ProductLabelPrefix is the prefix of the labels set on a LicenseInventory for each
+	// product line the cluster holds a license for, e.g. product.hub.licenses.appscode.com/kubedb=true.
+	ProductLabelPrefix = "product.hub.licenses.appscode.com/"
+	// ExpiresWithinLabel is set on a LicenseInventory to the smallest expiry window
+	// containing the earliest expiring license of the cluster.
+	ExpiresWithinLabel = "hub.licenses.appscode.com/expires-within"
+)
+
+// ExpiryWindow is the value of the ExpiresWithinLabel.
+// Select clusters with a license expiring within 30 days by expires-within in (expired,7d,30d).
+type ExpiryWindow string
+
+const (
+	ExpiryWindowExpired ExpiryWindow = "expired"
+	ExpiryWindow7d      ExpiryWindow = "7d"
+	ExpiryWindow30d     ExpiryWindow = "30d"
+	ExpiryWindow90d     ExpiryWindow = "90d"
+)
+
+// LicenseInventoryStatus summarizes the licenses of a ManagedCluster.
+type LicenseInventoryStatus struct {
+	// ClusterUID is the id.k8s.io ClusterClaim of the cluster.
+	// +optional
+	ClusterUID string `json:"clusterUID,omitempty"`
+	// ClaimedFeatures are the features in the licenses.appscode.com ClusterClaim of the cluster.
+	// +optional
+	ClaimedFeatures []string `json:"claimedFeatures,omitempty"`
+	// DeniedFeatures are the claimed features denied by LicensePolicies.
+	// +optional
+	DeniedFeatures []string `json:"deniedFeatures,omitempty"`
+	// UnlicensedFeatures are the allowed claimed features without a license.
+	// +optional
+	UnlicensedFeatures []string `json:"unlicensedFeatures,omitempty"`
+	// Licenses are the licenses synced to the cluster.
+	// +optional
+	Licenses []LicenseSummary `json:"licenses,omitempty"`
+	// EarliestExpiry is the expiry time of the earliest expiring license.
+	// +optional
+	EarliestExpiry *metav1.Time `json:"earliestExpiry,omitempty"`
+	// AcquisitionErrors are the errors returned by the license issuer for the last license request.
+	// +optional
+	AcquisitionErrors []string `json:"acquisitionErrors,omitempty"`
+	// Usage is the feature usage last reported by the agent of the cluster.
+	// +optional
+	Usage []FeatureUsage `json:"usage,omitempty"`
+	// UsageReportTime is the time the agent of the cluster last reported usage.
+	// +optional
+	UsageReportTime *metav1.Time `json:"usageReportTime,omitempty"`
+}
+
+// LicenseSummary describes a license held by a cluster.
+type LicenseSummary struct {
+	ID          string       `json:"id"`
+	ProductLine string       `json:"productLine,omitempty"`
+	TierName    string       `json:"tierName,omitempty"`
+	PlanName    string       `json:"planName,omitempty"`
+	Features    []string     `json:"features,omitempty"`
+	NotAfter    *metav1.Time `json:"notAfter,omitempty"`
+	// LastRequestTime is the last time a license was requested on the cluster for any feature of this license.
+	// Unset if the license was not requested since the agent started.
+	// +optional
+	LastRequestTime *metav1.Time `json:"lastRequestTime,omitempty"`
+	// Contract is known for licenses acquired by the running manager only.
+	// +optional
+	Contract *licenseapi.Contract `json:"contract,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Cluster UID",type="string",JSONPath=".status.clusterUID"
+//+kubebuilder:printcolumn:name="Earliest Expiry",type="date",JSONPath=".status.earliestExpiry"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// LicenseInventory is the read-only license summary of the ManagedCluster with the same name.
+// Inventories of ClusterProfiles are named clusterprofile.<namespace>.<name>.
+type LicenseInventory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status LicenseInventoryStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+
+// LicenseInventoryList contains a list of LicenseInventory
+type LicenseInventoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LicenseInventory `json:"items"`
+}
@@ -0,0 +1,72 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"slices"
+	"strings"
+
+	licenseapi "go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+)
+
+// AllowsFeature returns true if a license may be acquired for the feature and its product line.
+func (spec LicensePolicySpec) AllowsFeature(feature string) bool {
+	if slices.Contains(spec.DeniedFeatures, feature) {
+		return false
+	}
+	if len(spec.AllowedFeatures) > 0 && !slices.Contains(spec.AllowedFeatures, feature) {
+		return false
+	}
+	return spec.AllowsProductLine(FeatureProductLine(feature))
+}
+
+// AllowsProductLine returns true if licenses of the product line are allowed.
+func (spec LicensePolicySpec) AllowsProductLine(productLine string) bool {
+	if slices.Contains(spec.DeniedProductLines, productLine) {
+		return false
+	}
+	return len(spec.AllowedProductLines) == 0 || slices.Contains(spec.AllowedProductLines, productLine)
+}
+
+// AllowsLicense returns true if the tier of the license is allowed. The product line is checked
+// for the features before a license is acquired, the tier is known from the issued license only.
+func (spec LicensePolicySpec) AllowsLicense(l licenseapi.License) bool {
+	return spec.AllowsTier(Tier(l.TierName))
+}
+
+// AllowsTier returns true if the tier is not higher than the max tier. The top tier allows every
+// tier, including the empty tier of legacy licenses. Unknown tiers are denied by lower max tiers.
+func (spec LicensePolicySpec) AllowsTier(t Tier) bool {
+	if spec.MaxTier == "" || spec.MaxTier == TierEnterprise {
+		return true
+	}
+	rank, known := tierRanks[t]
+	maxRank, maxKnown := tierRanks[spec.MaxTier]
+	return known && maxKnown && rank <= maxRank
+}
+
+var tierRanks = map[Tier]int{
+	TierCommunity:  0,
+	TierEnterprise: 1,
+}
+
+// FeatureProductLine returns the product line of a feature, which is the prefix of its name,
+// like the product line of a license is the prefix of its plan name.
+func FeatureProductLine(feature string) string {
+	productLine, _, _ := strings.Cut(feature, "-")
+	return productLine
+}
@@ -0,0 +1,67 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestAllowsFeature(t *testing.T) {
+	spec := LicensePolicySpec{
+		DeniedFeatures:      []string{"kubedb-autoscaler"},
+		AllowedProductLines: []string{"kubedb", "kubestash"},
+	}
+	tests := []struct {
+		feature string
+		want    bool
+	}{
+		{"kubedb", true},
+		{"kubedb-ext-stash", true},
+		{"kubestash", true},
+		{"kubedb-autoscaler", false},
+		{"stash-enterprise", false},
+	}
+	for _, tt := range tests {
+		if got := spec.AllowsFeature(tt.feature); got != tt.want {
+			t.Errorf("AllowsFeature(%s) = %v, want %v", tt.feature, got, tt.want)
+		}
+	}
+}
+
+func TestAllowsTier(t *testing.T) {
+	tests := []struct {
+		maxTier Tier
+		tier    Tier
+		want    bool
+	}{
+		{"", "enterprise", true},
+		{TierCommunity, TierCommunity, true},
+		{TierCommunity, TierEnterprise, false},
+		{TierEnterprise, TierCommunity, true},
+		{TierEnterprise, "enterprize", true},
+		{TierEnterprise, "", true},
+		{TierCommunity, "", false},
+		{TierCommunity, "enterprize", false},
+		{"enterprize", TierCommunity, false},
+	}
+	for _, tt := range tests {
+		spec := LicensePolicySpec{MaxTier: tt.maxTier}
+		if got := spec.AllowsTier(tt.tier); got != tt.want {
+			t.Errorf("AllowsTier(%s) with maxTier %s = %v, want %v", tt.tier, tt.maxTier, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ResourceKindLicensePolicy = "LicensePolicy"
+	ResourceLicensePolicy     = "licensepolicy"
+	ResourceLicensePolicies   = "licensepolicies"
+)
+
+// LicensePolicySpec defines which features the selected clusters may receive licenses for.
+// A cluster selected by multiple policies must satisfy all of them.
+type LicensePolicySpec struct {
+	// ClusterSelector selects ManagedClusters by label.
+	// If both clusterSelector and clusterSets are empty, the policy selects all clusters.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// ClusterSets selects the members of these ManagedClusterSets. Other fleet members
+	// belong to the ClusterSet named by their x-k8s.io/cluster-set label, ClusterProfiles
+	// without the label to the ClusterSet named after their namespace.
+	// If clusterSelector is also set, a cluster must match both.
+	// +optional
+	ClusterSets []string `json:"clusterSets,omitempty"`
+
+	// AllowedFeatures lists the features a cluster may receive licenses for.
+	// If empty, all features not denied are allowed.
+	// +optional
+	AllowedFeatures []string `json:"allowedFeatures,omitempty"`
+	// DeniedFeatures lists the features a cluster must not receive licenses for.
+	// +optional
+	DeniedFeatures []string `json:"deniedFeatures,omitempty"`
+	// AllowedProductLines lists the product lines a license may belong to.
+	// The product line of a feature is the prefix of its name, up to the first dash.
+	// If empty, all product lines not denied are allowed.
+	// +optional
+	AllowedProductLines []string `json:"allowedProductLines,omitempty"`
+	// DeniedProductLines lists the product lines a license must not belong to.
+	// +optional
+	DeniedProductLines []string `json:"deniedProductLines,omitempty"`
+	// MaxTier is the highest license tier a cluster may receive.
+	// The enterprise tier allows licenses of every tier, lower tiers deny licenses of unknown tiers.
+	// +optional
+	MaxTier Tier `json:"maxTier,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=community;enterprise
+type Tier string
+
+const (
+	TierCommunity  Tier = "community"
+	TierEnterprise Tier = "enterprise"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// LicensePolicy is the Schema for the licensepolicies API
+type LicensePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec LicensePolicySpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+//+kubebuilder:object:root=true
+
+// LicensePolicyList contains a list of LicensePolicy
+type LicensePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LicensePolicy `json:"items"`
+}
@@ -0,0 +1,42 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UsageReport is published by the license-proxyserver agent of a cluster in its cluster namespace on the hub.
+// It is built from the license requests served since the agent started.
+type UsageReport struct {
+	ClusterUID string         `json:"clusterUID"`
+	ReportTime metav1.Time    `json:"reportTime"`
+	Features   []FeatureUsage `json:"features,omitempty"`
+}
+
+// FeatureUsage describes the license requests made for a feature.
+type FeatureUsage struct {
+	Feature         string            `json:"feature"`
+	LastRequestTime metav1.Time       `json:"lastRequestTime"`
+	Consumers       []FeatureConsumer `json:"consumers,omitempty"`
+}
+
+// FeatureConsumer is a user that requested a license for a feature.
+type FeatureConsumer struct {
+	Username        string      `json:"username"`
+	LastRequestTime metav1.Time `json:"lastRequestTime"`
+}
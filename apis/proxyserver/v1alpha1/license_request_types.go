@@ -44,8 +44,26 @@ type LicenseRequest struct {
 
 type LicenseRequestRequest struct {
 	Features []string `json:"features"`
+	// WaitTimeout is how long to wait for the hub to deliver a license,
+	// if none is available on an OCM spoke. It is capped by the server.
+	// +optional
+	WaitTimeout *metav1.Duration `json:"waitTimeout,omitempty"`
 }
 
+// +kubebuilder:validation:Enum=Found;NotFound;TimedOut
+type LicenseRequestResult string
+
+const (
+	// LicenseRequestFound means the response contains a license.
+	LicenseRequestFound LicenseRequestResult = "Found"
+	// LicenseRequestNotFound means no license is available and the request did not wait for one.
+	LicenseRequestNotFound LicenseRequestResult = "NotFound"
+	// LicenseRequestTimedOut means no license was delivered within the wait timeout.
+	LicenseRequestTimedOut LicenseRequestResult = "TimedOut"
+)
+
 type LicenseRequestResponse struct {
 	License string `json:"license"`
+	// +optional
+	Result LicenseRequestResult `json:"result,omitempty"`
 }
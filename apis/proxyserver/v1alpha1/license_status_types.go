@@ -71,6 +71,9 @@ func (t ExtraValue) String() string {
 type LicenseStatusStatus struct {
 	Contract *licenseapi.Contract `json:"contract,omitempty"`
 	License  licenseapi.License   `json:"license"`
+	// VerifiedBy is the name of the license CA that verified this license.
+	// +optional
+	VerifiedBy string `json:"verifiedBy,omitempty"`
 }
 
 // +genclient
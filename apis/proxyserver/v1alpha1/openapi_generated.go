@@ -463,10 +463,18 @@ func schema_license_proxyserver_apis_proxyserver_v1alpha1_LicenseRequestRequest(
 							},
 						},
 					},
+					"waitTimeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "WaitTimeout is how long to wait for the hub to deliver a license, if none is available on an OCM spoke. It is capped by the server.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
 				},
 				Required: []string{"features"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
 	}
 }
 
@@ -483,6 +491,12 @@ func schema_license_proxyserver_apis_proxyserver_v1alpha1_LicenseRequestResponse
 							Format:  "",
 						},
 					},
+					"result": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
 				},
 				Required: []string{"license"},
 			},
@@ -640,6 +654,13 @@ func schema_license_proxyserver_apis_proxyserver_v1alpha1_LicenseStatusStatus(re
 							Ref:     ref("go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1.License"),
 						},
 					},
+					"verifiedBy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "VerifiedBy is the name of the license CA that verified this license.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"license"},
 			},
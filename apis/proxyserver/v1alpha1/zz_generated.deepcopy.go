@@ -24,6 +24,7 @@ package v1alpha1
 import (
 	licensesv1alpha1 "go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
 
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -90,6 +91,11 @@ func (in *LicenseRequestRequest) DeepCopyInto(out *LicenseRequestRequest) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.WaitTimeout != nil {
+		in, out := &in.WaitTimeout, &out.WaitTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	return
 }
 
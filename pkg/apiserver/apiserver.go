@@ -20,31 +20,32 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"go.bytebuilders.dev/license-proxyserver/apis/proxyserver"
 	proxyserverinstall "go.bytebuilders.dev/license-proxyserver/apis/proxyserver/install"
 	proxyserverv1alpha1 "go.bytebuilders.dev/license-proxyserver/apis/proxyserver/v1alpha1"
-	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/clusterclaim"
 	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/secret"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
 	"go.bytebuilders.dev/license-proxyserver/pkg/registry/proxyserver/licenserequest"
 	"go.bytebuilders.dev/license-proxyserver/pkg/registry/proxyserver/licensestatus"
 	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
 	pc "go.bytebuilders.dev/license-verifier/client"
-	"go.bytebuilders.dev/license-verifier/info"
 
 	v "gomodules.xyz/x/version"
 	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/healthz"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	restclient "k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 	cu "kmodules.xyz/client-go/client"
 	clustermeta "kmodules.xyz/client-go/cluster"
@@ -52,7 +53,6 @@ import (
 	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
 	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -97,10 +97,16 @@ type ExtraConfig struct {
 	Token                 string
 	CACert                []byte
 	InsecureSkipTLSVerify bool
+	LicenseCAFile         string
 	LicenseDir            string
 	CacheDir              string
 	HubKubeconfig         string
 	SpokeClusterName      string
+	UsageReportInterval   time.Duration
+	ClaimFeatureTTL       time.Duration
+	HubSyncInterval       time.Duration
+	FleetBackend          string
+	FleetNamespace        string
 }
 
 // Config defines the config for the apiserver
@@ -112,8 +118,11 @@ type Config struct {
 // LicenseProxyServer contains state for a Kubernetes cluster master/api server.
 type LicenseProxyServer struct {
 	GenericAPIServer *genericapiserver.GenericAPIServer
-	HubManager       manager.Manager
-	SpokeManager     manager.Manager
+	// HubManager syncs licenses from the hub, if running on a spoke cluster
+	HubManager manager.Runnable
+	// HubSync tracks the health of license syncs from the hub
+	HubSync      *secret.SyncHealth
+	SpokeManager manager.Manager
 }
 
 type completedConfig struct {
@@ -167,18 +176,41 @@ func (c completedConfig) New(ctx context.Context) (*LicenseProxyServer, error) {
 		os.Exit(1)
 	}
 
-	isSpokeCluster := clustermeta.IsACEManagedSpoke(spokeManager.GetAPIReader())
-
 	cid, err := clustermeta.ClusterUID(spokeManager.GetAPIReader())
 	if err != nil {
 		return nil, err
 	}
 
-	caData, err := info.LoadLicenseCA()
-	if err != nil {
-		return nil, err
+	var spoke fleet.Spoke
+	var isSpokeCluster bool
+	switch c.ExtraConfig.FleetBackend {
+	case fleet.BackendOCM:
+		isSpokeCluster = clustermeta.IsACEManagedSpoke(spokeManager.GetAPIReader())
+		spoke = &fleet.OCMSpoke{
+			Client:      spokeManager.GetClient(),
+			ClusterName: c.ExtraConfig.SpokeClusterName,
+		}
+	case fleet.BackendShared:
+		isSpokeCluster = true
+		spoke = &fleet.SharedSpoke{
+			HubKubeconfig: c.ExtraConfig.HubKubeconfig,
+			Namespace:     c.ExtraConfig.FleetNamespace,
+			ClusterName:   c.ExtraConfig.SpokeClusterName,
+			ClusterID:     cid,
+		}
+	case fleet.BackendClusterProfile:
+		isSpokeCluster = true
+		spoke = &fleet.ClusterProfileSpoke{
+			HubKubeconfig: c.ExtraConfig.HubKubeconfig,
+			Namespace:     c.ExtraConfig.FleetNamespace,
+			ClusterName:   c.ExtraConfig.SpokeClusterName,
+			ClusterID:     cid,
+		}
+	default:
+		return nil, fmt.Errorf("unknown fleet backend %q", c.ExtraConfig.FleetBackend)
 	}
-	caCert, err := info.ParseCertificate(caData)
+
+	caBundle, err := trust.LoadBundle(c.ExtraConfig.LicenseCAFile)
 	if err != nil {
 		return nil, err
 	}
@@ -202,16 +234,22 @@ func (c completedConfig) New(ctx context.Context) (*LicenseProxyServer, error) {
 	rb := storage.NewRecordBook()
 	reg := storage.NewLicenseRegistry(c.ExtraConfig.CacheDir, storage.MinRemainingLife, rb)
 	if c.ExtraConfig.LicenseDir != "" {
-		err = storage.LoadDir(cid, c.ExtraConfig.LicenseDir, reg)
+		invalid, err := storage.LoadDir(cid, c.ExtraConfig.LicenseDir, caBundle, reg)
 		if err != nil {
 			return nil, err
 		}
+		for file, err := range invalid {
+			klog.ErrorS(err, "Skipping", "file", file)
+		}
 	}
 	if c.ExtraConfig.CacheDir != "" {
-		err = storage.LoadDir(cid, c.ExtraConfig.CacheDir, reg)
+		invalid, err := storage.LoadDir(cid, c.ExtraConfig.CacheDir, caBundle, reg)
 		if err != nil {
 			return nil, err
 		}
+		for file, err := range invalid {
+			klog.ErrorS(err, "Skipping", "file", file)
+		}
 	}
 
 	s := &LicenseProxyServer{
@@ -219,6 +257,25 @@ func (c completedConfig) New(ctx context.Context) (*LicenseProxyServer, error) {
 		SpokeManager:     spokeManager,
 	}
 
+	// standalone clusters acquire licenses directly and have no license claim
+	var claims *clusterclaim.Updater
+	if isSpokeCluster {
+		claims = clusterclaim.NewUpdater(spoke)
+		if err := claims.SetupWithManager(spokeManager); err != nil {
+			setupLog.Error(err, "unable to add license claim updater")
+			os.Exit(1)
+		}
+		if c.ExtraConfig.ClaimFeatureTTL > 0 {
+			if err := (&clusterclaim.Pruner{
+				Spoke: spoke,
+				TTL:   c.ExtraConfig.ClaimFeatureTTL,
+			}).SetupWithManager(spokeManager); err != nil {
+				setupLog.Error(err, "unable to add license claim pruner")
+				os.Exit(1)
+			}
+		}
+	}
+
 	if isSpokeCluster {
 		if c.ExtraConfig.SpokeClusterName == "" {
 			return nil, fmt.Errorf("missing --cluster-name")
@@ -230,54 +287,23 @@ func (c completedConfig) New(ctx context.Context) (*LicenseProxyServer, error) {
 			return nil, fmt.Errorf("missing --license-dir")
 		}
 
-		// get hub kubeconfig
-		hubConfig, err := clientcmd.BuildConfigFromFlags("", c.ExtraConfig.HubKubeconfig)
-		if err != nil {
-			setupLog.Error(err, "unable to build hub rest config")
-			os.Exit(1)
-		}
-
-		s.HubManager, err = manager.New(hubConfig, manager.Options{
-			Scheme:                 clientgoscheme.Scheme,
-			Metrics:                metricsserver.Options{BindAddress: "0"},
-			HealthProbeBindAddress: "",
-			LeaderElection:         false,
-			LeaderElectionID:       "5b87adeb-hub.proxyserver.licenses.appscode.com",
-			NewClient:              cu.NewClient,
-			Cache: cache.Options{
-				ByObject: map[client.Object]cache.ByObject{
-					&core.Secret{}: {
-						Namespaces: map[string]cache.Config{
-							c.ExtraConfig.SpokeClusterName: {
-								FieldSelector: fields.OneTermEqualSelector("metadata.name", common.LicenseSecret),
-							},
-						},
-					},
-				},
+		s.HubSync = secret.NewSyncHealth(3 * c.ExtraConfig.HubSyncInterval)
+		s.HubManager = &hubRunner{
+			newManager: func() (manager.Manager, error) {
+				return c.newHubManager(spokeManager.GetClient(), spoke, cid, caBundle, reg, rb, s.HubSync)
 			},
-		})
-		if err != nil {
-			setupLog.Error(err, "unable to start hub manager")
-			os.Exit(1)
-		}
-
-		if err := (&secret.LicenseSyncer{
-			HubClient:   s.HubManager.GetClient(),
-			SpokeClient: spokeManager.GetClient(),
-			ClusterID:   cid,
-			CaCert:      caCert,
-			R:           reg,
-		}).SetupWithManager(s.HubManager); err != nil {
-			setupLog.Error(err, "unable to create controller", "controller", "LicenseSyncer")
-			os.Exit(1)
+			health:     s.HubSync,
+			kubeconfig: c.ExtraConfig.HubKubeconfig,
 		}
+		healthz.InstallPathHandler(genericServer.Handler.NonGoRestfulMux, secret.HealthzPath, s.HubSync)
+		genericServer.Handler.NonGoRestfulMux.Handle(secret.StatusPath, s.HubSync)
 	}
 
 	{
 		apiGroupInfo := genericapiserver.NewDefaultAPIGroupInfo(proxyserver.GroupName, Scheme, metav1.ParameterCodec, Codecs)
 
 		v1alpha1storage := map[string]rest.Storage{}
-		v1alpha1storage[proxyserverv1alpha1.ResourceLicenseRequests] = licenserequest.NewStorage(cid, caCert, lc, reg, rb, spokeManager.GetClient())
+		v1alpha1storage[proxyserverv1alpha1.ResourceLicenseRequests] = licenserequest.NewStorage(cid, caBundle, lc, reg, rb, isSpokeCluster, claims)
 		v1alpha1storage[proxyserverv1alpha1.ResourceLicenseStatuses] = licensestatus.NewStorage(reg, rb)
 		apiGroupInfo.VersionedResourcesStorageMap["v1alpha1"] = v1alpha1storage
 
@@ -0,0 +1,221 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/secret"
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/usage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	cu "kmodules.xyz/client-go/client"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// kubeconfigPollInterval is the interval the hub kubeconfig is checked for changes.
+var kubeconfigPollInterval = 30 * time.Second
+
+// hubRunner runs the hub manager and recreates it after it fails, e.g. because
+// the hub is unreachable. The manager is also recreated when the hub kubeconfig,
+// or a file it references, is rotated on disk by the addon agent, as a running
+// manager keeps the token and embedded credentials it was created with.
+// Licenses keep being served from the local secret and cache meanwhile.
+type hubRunner struct {
+	newManager func() (manager.Manager, error)
+	health     *secret.SyncHealth
+	kubeconfig string
+}
+
+var _ manager.Runnable = &hubRunner{}
+
+func newHubBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: 10 * time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    10,
+		Cap:      5 * time.Minute,
+	}
+}
+
+func (r *hubRunner) Start(ctx context.Context) error {
+	backoff := newHubBackoff()
+	for {
+		started := time.Now()
+		rotated, err := r.run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if rotated {
+			klog.InfoS("hub kubeconfig changed, restarting hub manager")
+			backoff = newHubBackoff()
+			continue
+		}
+		if err == nil {
+			err = errors.New("hub manager stopped")
+		}
+		r.health.RecordError(err)
+
+		// a failure after a long healthy run is not retried with the delay of earlier failures
+		if time.Since(started) > backoff.Cap {
+			backoff = newHubBackoff()
+		}
+		delay := backoff.Step()
+		klog.ErrorS(err, "hub manager failed, restarting", "after", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// run runs a hub manager until it fails or the hub kubeconfig changes. It returns true
+// if the manager was stopped because the hub kubeconfig changed.
+func (r *hubRunner) run(ctx context.Context) (bool, error) {
+	fingerprint, err := kubeconfigFingerprint(r.kubeconfig)
+	if err != nil {
+		return false, err
+	}
+	mgr, err := r.newManager()
+	if err != nil {
+		return false, err
+	}
+
+	mgrCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var rotated atomic.Bool
+	go wait.UntilWithContext(mgrCtx, func(ctx context.Context) {
+		cur, err := kubeconfigFingerprint(r.kubeconfig)
+		if err != nil {
+			// the files may be in the middle of an update
+			klog.ErrorS(err, "failed to read hub kubeconfig")
+			return
+		}
+		if !bytes.Equal(cur, fingerprint) {
+			rotated.Store(true)
+			cancel()
+		}
+	}, kubeconfigPollInterval)
+
+	klog.InfoS("starting hub manager")
+	err = mgr.Start(mgrCtx)
+	return rotated.Load(), err
+}
+
+// kubeconfigFingerprint returns a hash of the kubeconfig and the certificate files it references.
+func kubeconfigFingerprint(path string) ([]byte, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build hub rest config")
+	}
+	h := sha256.New()
+	for _, file := range []string{path, cfg.CertFile, cfg.KeyFile, cfg.CAFile} {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+	}
+	return h.Sum(nil), nil
+}
+
+func (c completedConfig) newHubManager(spokeClient client.Client, spoke fleet.Spoke, cid string, caBundle *trust.Bundle, reg *storage.LicenseRegistry, rb *storage.RecordBook, health *secret.SyncHealth) (manager.Manager, error) {
+	// get hub kubeconfig
+	hubConfig, err := clientcmd.BuildConfigFromFlags("", c.ExtraConfig.HubKubeconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build hub rest config")
+	}
+
+	mgr, err := manager.New(hubConfig, manager.Options{
+		Scheme:                 clientgoscheme.Scheme,
+		Metrics:                metricsserver.Options{BindAddress: "0"},
+		HealthProbeBindAddress: "",
+		LeaderElection:         false,
+		LeaderElectionID:       "5b87adeb-hub.proxyserver.licenses.appscode.com",
+		NewClient:              cu.NewClient,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&core.Secret{}: {
+					Namespaces: map[string]cache.Config{
+						spoke.LicenseSecret().Namespace: {
+							FieldSelector: fields.OneTermEqualSelector("metadata.name", spoke.LicenseSecret().Name),
+						},
+					},
+				},
+			},
+		},
+		// the controllers are registered again when the hub manager is recreated
+		Controller: config.Controller{
+			SkipNameValidation: ptr.To(true),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create hub manager")
+	}
+
+	if err := (&secret.LicenseSyncer{
+		HubReader:    mgr.GetAPIReader(),
+		SpokeClient:  spokeClient,
+		Fleet:        spoke,
+		ClusterID:    cid,
+		CABundle:     caBundle,
+		R:            reg,
+		Health:       health,
+		ResyncPeriod: c.ExtraConfig.HubSyncInterval,
+	}).SetupWithManager(mgr); err != nil {
+		return nil, errors.Wrap(err, "unable to create LicenseSyncer")
+	}
+
+	// the usage secret is set up by the OCM addon manager
+	if c.ExtraConfig.UsageReportInterval > 0 && c.ExtraConfig.FleetBackend == fleet.BackendOCM {
+		if err := (&usage.Reporter{
+			HubClient:   mgr.GetClient(),
+			HubReader:   mgr.GetAPIReader(),
+			ClusterName: c.ExtraConfig.SpokeClusterName,
+			ClusterID:   cid,
+			RecordBook:  rb,
+			Interval:    c.ExtraConfig.UsageReportInterval,
+		}).SetupWithManager(mgr); err != nil {
+			return nil, errors.Wrap(err, "unable to add usage reporter")
+		}
+	}
+	return mgr, nil
+}
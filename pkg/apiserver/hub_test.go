@@ -0,0 +1,145 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/secret"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: hub
+  context:
+    cluster: hub
+    user: agent
+current-context: hub
+users:
+- name: agent
+  user:
+    client-certificate: tls.crt
+    client-key: tls.key
+`
+
+func writeKubeconfig(t *testing.T, dir, cert string) string {
+	t.Helper()
+	path := filepath.Join(dir, "kubeconfig")
+	for name, data := range map[string]string{"kubeconfig": testKubeconfig, "tls.crt": cert, "tls.key": "key"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestKubeconfigFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	path := writeKubeconfig(t, dir, "cert-1")
+	fp1, err := kubeconfigFingerprint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp2, err := kubeconfigFingerprint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fp1) != string(fp2) {
+		t.Error("fingerprint of an unchanged kubeconfig changed")
+	}
+
+	// the addon agent rotates the client certificate next to the kubeconfig
+	writeKubeconfig(t, dir, "cert-2")
+	fp3, err := kubeconfigFingerprint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fp1) == string(fp3) {
+		t.Error("fingerprint did not change with the client certificate")
+	}
+}
+
+func TestHubRunnerRestartsOnKubeconfigRotation(t *testing.T) {
+	orig := kubeconfigPollInterval
+	kubeconfigPollInterval = 50 * time.Millisecond
+	defer func() {
+		kubeconfigPollInterval = orig
+	}()
+
+	dir := t.TempDir()
+	path := writeKubeconfig(t, dir, "cert-1")
+	var started atomic.Int32
+	r := &hubRunner{
+		newManager: func() (manager.Manager, error) {
+			started.Add(1)
+			cfg, err := clientcmd.BuildConfigFromFlags("", path)
+			if err != nil {
+				return nil, err
+			}
+			return manager.New(cfg, manager.Options{
+				Metrics:    metricsserver.Options{BindAddress: "0"},
+				Controller: config.Controller{SkipNameValidation: ptr.To(true)},
+			})
+		},
+		// NewSyncHealth registers metrics, which can be done once per process only
+		health:     &secret.SyncHealth{StaleAfter: time.Minute},
+		kubeconfig: path,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		_ = r.Start(ctx)
+		close(done)
+	}()
+
+	if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return started.Load() == 1, nil
+	}); err != nil {
+		t.Fatal("hub manager was not started")
+	}
+	writeKubeconfig(t, dir, "cert-2")
+	if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return started.Load() == 2, nil
+	}); err != nil {
+		t.Fatal("hub manager was not recreated after the kubeconfig changed")
+	}
+	if status := r.health.Status(); status.LastError != "" {
+		t.Errorf("kubeconfig rotation was recorded as a sync error: %s", status.LastError)
+	}
+
+	cancel()
+	<-done
+}
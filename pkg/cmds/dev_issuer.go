@@ -0,0 +1,130 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+
+	"github.com/spf13/cobra"
+	"gomodules.xyz/cert"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/klog/v2"
+)
+
+func NewCmdDevIssuer() *cobra.Command {
+	var (
+		listenAddress string
+		caCertFile    string
+		caKeyFile     string
+		status        string
+		faults        devissuer.Faults
+	)
+	opts := devissuer.DefaultLicenseOptions()
+
+	cmd := &cobra.Command{
+		Use:               "dev-issuer",
+		Short:             "Launch a fake license issuer for local development and tests",
+		Long:              "Launch a fake license issuer for local development and tests. Point the proxy --baseURL to this server and --license-ca-file to its CA cert.",
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			opts.Status, err = devissuer.ParseStatus(status)
+			if err != nil {
+				return err
+			}
+
+			iss, err := loadOrCreateIssuer(caCertFile, caKeyFile, opts)
+			if err != nil {
+				return err
+			}
+			iss.SetFaults(faults)
+
+			srv := &http.Server{
+				Addr:              listenAddress,
+				Handler:           iss.Handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			ctx := genericapiserver.SetupSignalContext()
+			go func() {
+				<-ctx.Done()
+				_ = srv.Shutdown(context.Background())
+			}()
+
+			klog.Infof("Starting dev license issuer on %s", listenAddress)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&listenAddress, "listen-address", ":8080", "Address the issuer listens on")
+	flags.StringVar(&caCertFile, "ca-cert-file", "", "Path to CA cert file. Generated and written here if missing")
+	flags.StringVar(&caKeyFile, "ca-key-file", "", "Path to CA key file. Generated and written here if missing")
+	flags.StringVar(&opts.ProductLine, "product-line", opts.ProductLine, "Product line of issued licenses")
+	flags.StringVar(&opts.TierName, "tier", opts.TierName, "Tier of issued licenses")
+	flags.StringVar(&opts.PlanName, "plan", opts.PlanName, "Plan name of issued licenses")
+	flags.DurationVar(&opts.Duration, "duration", opts.Duration, "Duration of issued licenses")
+	flags.StringVar(&status, "status", string(opts.Status), "Status of issued licenses, one of active, expired or invalid")
+	flags.IntVar(&faults.StatusCode, "fail-with-status", faults.StatusCode, "If set, every license request fails with this HTTP status code")
+	flags.DurationVar(&faults.Delay, "response-delay", faults.Delay, "Delay added before responding to every license request")
+
+	return cmd
+}
+
+func loadOrCreateIssuer(caCertFile, caKeyFile string, opts devissuer.LicenseOptions) (*devissuer.Issuer, error) {
+	if caCertFile != "" && caKeyFile != "" {
+		if ok, _ := cert.CanReadCertAndKey(caCertFile, caKeyFile); ok {
+			crt, err := os.ReadFile(caCertFile)
+			if err != nil {
+				return nil, err
+			}
+			key, err := os.ReadFile(caKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			return devissuer.NewFromPEM(crt, key, opts)
+		}
+	}
+
+	iss, err := devissuer.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if caCertFile != "" {
+		if err := cert.WriteCert(caCertFile, iss.CACertPEM()); err != nil {
+			return nil, err
+		}
+	}
+	if caKeyFile != "" {
+		key, err := iss.CAKeyPEM()
+		if err != nil {
+			return nil, err
+		}
+		if err := cert.WriteKey(caKeyFile, key); err != nil {
+			return nil, err
+		}
+	}
+	return iss, nil
+}
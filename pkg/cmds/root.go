@@ -35,6 +35,7 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(v.NewCmdVersion())
 	rootCmd.AddCommand(NewCmdRun(os.Stdout, os.Stderr))
 	rootCmd.AddCommand(manager.NewManagerCommand())
+	rootCmd.AddCommand(NewCmdDevIssuer())
 
 	return rootCmd
 }
@@ -18,8 +18,11 @@ package server
 
 import (
 	"os"
+	"time"
 
 	"go.bytebuilders.dev/license-proxyserver/pkg/apiserver"
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/clusterclaim"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
@@ -33,17 +36,26 @@ type ExtraOptions struct {
 	Token                 string
 	CAFile                string
 	InsecureSkipTLSVerify bool
+	LicenseCAFile         string
 	LicenseDir            string
 	CacheDir              string
 
-	HubKubeconfig    string
-	SpokeClusterName string
+	HubKubeconfig       string
+	SpokeClusterName    string
+	UsageReportInterval time.Duration
+	ClaimFeatureTTL     time.Duration
+	HubSyncInterval     time.Duration
+	FleetBackend        string
+	FleetNamespace      string
 }
 
 func NewExtraOptions() *ExtraOptions {
 	return &ExtraOptions{
-		QPS:   1e6,
-		Burst: 1e6,
+		QPS:                 1e6,
+		Burst:               1e6,
+		UsageReportInterval: 10 * time.Minute,
+		HubSyncInterval:     5 * time.Minute,
+		FleetBackend:        fleet.BackendOCM,
 	}
 }
 
@@ -54,10 +66,16 @@ func (s *ExtraOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&s.Token, "token", s.Token, "License server token")
 	fs.StringVar(&s.CAFile, "ca-file", s.CAFile, "Path to custom CA cert file used to issue appscode.com cert")
 	fs.BoolVar(&s.InsecureSkipTLSVerify, "insecure-skip-tls-verify", s.InsecureSkipTLSVerify, "If true, skips verifying appscode.com cert")
+	fs.StringVar(&s.LicenseCAFile, "license-ca-file", s.LicenseCAFile, "Path to CA bundle used to verify licenses. May contain multiple certificates. Defaults to the AppsCode license CA")
 	fs.StringVar(&s.LicenseDir, "license-dir", s.LicenseDir, "Path to license directory")
 	fs.StringVar(&s.CacheDir, "cache-dir", s.CacheDir, "Path to license cache directory")
 	fs.StringVar(&s.HubKubeconfig, "hub-kubeconfig", s.HubKubeconfig, "Path to hub kubeconfig")
 	fs.StringVar(&s.SpokeClusterName, "cluster-name", s.SpokeClusterName, "Spoke Cluster name")
+	fs.DurationVar(&s.ClaimFeatureTTL, "claim-feature-ttl", s.ClaimFeatureTTL, "Remove features from the license ClusterClaim if no license was requested for them within this period. Set to 0 to never remove features")
+	fs.DurationVar(&s.UsageReportInterval, "usage-report-interval", s.UsageReportInterval, "Interval to report license usage to the hub. Set to 0 to disable usage reporting")
+	fs.DurationVar(&s.HubSyncInterval, "hub-sync-interval", s.HubSyncInterval, "Interval to resync licenses from the hub. Hub sync is reported unhealthy if licenses were not synced for 3 intervals")
+	fs.StringVar(&s.FleetBackend, "fleet-backend", s.FleetBackend, "Backend used to get licenses from the hub. One of ocm, shared or clusterprofile")
+	fs.StringVar(&s.FleetNamespace, "fleet-namespace", s.FleetNamespace, "Hub namespace shared by the clusters of the fleet. Used by the shared and clusterprofile fleet backends")
 }
 
 func (s *ExtraOptions) ApplyTo(cfg *apiserver.ExtraConfig) error {
@@ -71,10 +89,16 @@ func (s *ExtraOptions) ApplyTo(cfg *apiserver.ExtraConfig) error {
 		cfg.CACert = caCert
 	}
 	cfg.InsecureSkipTLSVerify = s.InsecureSkipTLSVerify
+	cfg.LicenseCAFile = s.LicenseCAFile
 	cfg.LicenseDir = s.LicenseDir
 	cfg.CacheDir = s.CacheDir
 	cfg.HubKubeconfig = s.HubKubeconfig
 	cfg.SpokeClusterName = s.SpokeClusterName
+	cfg.UsageReportInterval = s.UsageReportInterval
+	cfg.ClaimFeatureTTL = s.ClaimFeatureTTL
+	cfg.HubSyncInterval = s.HubSyncInterval
+	cfg.FleetBackend = s.FleetBackend
+	cfg.FleetNamespace = s.FleetNamespace
 	cfg.ClientConfig.QPS = float32(s.QPS)
 	cfg.ClientConfig.Burst = s.Burst
 
@@ -82,5 +106,21 @@ func (s *ExtraOptions) ApplyTo(cfg *apiserver.ExtraConfig) error {
 }
 
 func (s *ExtraOptions) Validate() []error {
-	return nil
+	var errs []error
+	if s.HubSyncInterval <= 0 {
+		errs = append(errs, errors.New("--hub-sync-interval must be positive"))
+	}
+	if s.ClaimFeatureTTL != 0 && s.ClaimFeatureTTL < clusterclaim.MinFeatureTTL {
+		errs = append(errs, errors.Errorf("--claim-feature-ttl must be 0 or at least %s", clusterclaim.MinFeatureTTL))
+	}
+	switch s.FleetBackend {
+	case fleet.BackendOCM:
+	case fleet.BackendShared, fleet.BackendClusterProfile:
+		if s.FleetNamespace == "" {
+			errs = append(errs, errors.Errorf("--fleet-namespace is required by the %s fleet backend", s.FleetBackend))
+		}
+	default:
+		errs = append(errs, errors.Errorf("unknown --fleet-backend %q", s.FleetBackend))
+	}
+	return errs
 }
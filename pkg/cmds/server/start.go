@@ -169,7 +169,6 @@ func (o LicenseProxyServerOptions) Run(ctx context.Context) error {
 
 	if server.HubManager != nil {
 		err = server.SpokeManager.Add(manager.RunnableFunc(func(ctx context.Context) error {
-			setupLog.Info("starting hub manager")
 			return server.HubManager.Start(ctx)
 		}))
 		if err != nil {
@@ -18,7 +18,6 @@ package common
 
 import (
 	"os"
-	"time"
 
 	meta_util "kmodules.xyz/client-go/meta"
 )
@@ -28,6 +27,42 @@ const (
 	ClusterClaimLicense     = "licenses.appscode.com"
 	LicenseSecret           = "license-proxyserver-licenses"
 	HubKubeconfigSecretName = "license-proxyserver-hub-kubeconfig"
+
+	// LicenseIndexKey holds the feature -> license ID index in the license secret.
+	// Other keys in the secret are license IDs or, in the old layout, plan names.
+	LicenseIndexKey = "index.json"
+
+	// ClusterUIDAnnotation records the cluster UID the licenses in the hub license secret were issued for.
+	ClusterUIDAnnotation = "licenses.appscode.com/cluster-uid"
+	// LicenseCleanupFinalizer is set on fleet members with license state on the hub to clean it up on removal.
+	LicenseCleanupFinalizer = "licenses.appscode.com/cleanup"
+
+	// ClusterClaimLastRequestedAnnotation records the last time a license was requested for each feature
+	// in the license ClusterClaim, as a JSON map of feature to RFC 3339 timestamp.
+	ClusterClaimLastRequestedAnnotation = "licenses.appscode.com/last-requested"
+
+	// LicenseSyncStatusAnnotation records the sync status of each entry of the hub license secret
+	// in the spoke license secret, as a JSON map of secret key to status or error.
+	LicenseSyncStatusAnnotation = "licenses.appscode.com/sync-status"
+
+	// LicenseUsageSecret is updated by the agent in its cluster namespace on the hub with a usage report.
+	LicenseUsageSecret = "license-proxyserver-usage"
+	// LicenseUsageKey holds the JSON encoded usage report in the usage secret.
+	LicenseUsageKey = "usage.json"
+
+	// IssuerCredentialsLabel marks the secrets in the manager namespace holding license issuer
+	// credentials. On a cluster, the annotation with the same key names the secret used for it.
+	IssuerCredentialsLabel = "licenses.appscode.com/issuer-credentials"
+	// IssuerClusterSelectorAnnotation is a label selector of the clusters an issuer credentials secret is used for.
+	IssuerClusterSelectorAnnotation = "licenses.appscode.com/cluster-selector"
+	// IssuerClusterSetsAnnotation is a comma separated list of the ManagedClusterSets an issuer credentials secret is used for.
+	IssuerClusterSetsAnnotation = "licenses.appscode.com/cluster-sets"
+
+	// Keys of an issuer credentials secret
+	IssuerBaseURLKey               = "baseURL"
+	IssuerTokenKey                 = "token"
+	IssuerCAKey                    = "ca.crt"
+	IssuerInsecureSkipTLSVerifyKey = "insecureSkipTLSVerify"
 )
 
 const (
@@ -37,8 +72,6 @@ const (
 	AddonInstallationNamespace = "kubeops"
 	AgentConfigSecretName      = "license-proxyserver-config"
 
-	Duration10Yrs  = 10 * 365 * 24 * time.Hour
-	CACertName     = "ca"
 	ServerCertName = "tls"
 )
 
@@ -0,0 +1,91 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterclaim
+
+import (
+	"context"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Pruner removes features from the claim of the cluster that were not requested for TTL,
+// so that the hub stops renewing licenses for uninstalled products.
+type Pruner struct {
+	Spoke fleet.Spoke
+	TTL   time.Duration
+}
+
+var _ manager.LeaderElectionRunnable = &Pruner{}
+
+// SetupWithManager adds the pruner to the spoke manager.
+func (p *Pruner) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(p)
+}
+
+func (p *Pruner) NeedLeaderElection() bool {
+	return false
+}
+
+func (p *Pruner) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := p.prune(ctx); err != nil {
+			klog.ErrorS(err, "failed to prune license claim")
+		}
+	}, min(p.TTL, time.Hour))
+	return nil
+}
+
+func (p *Pruner) prune(ctx context.Context) error {
+	var pruned []string
+	err := p.Spoke.UpdateClaim(ctx, func(claim *fleet.Claim, exists bool) (bool, error) {
+		pruned = nil
+		if !exists {
+			return false, nil
+		}
+
+		now := metav1.Now()
+		var changed bool
+		for _, feature := range sets.List(claim.Features) {
+			t, found := claim.LastRequested[feature]
+			if !found {
+				// claimed before requests were tracked, start the clock now
+				claim.LastRequested[feature] = now
+				changed = true
+			} else if now.Sub(t.Time) > p.TTL {
+				claim.Features.Delete(feature)
+				pruned = append(pruned, feature)
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(pruned) > 0 {
+		klog.InfoS("pruned unused features from license claim", "features", pruned, "ttl", p.TTL)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterclaim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeSpoke keeps the claim of the cluster in memory.
+type fakeSpoke struct {
+	claim *fleet.Claim
+}
+
+var _ fleet.Spoke = &fakeSpoke{}
+
+func (s *fakeSpoke) UpdateClaim(_ context.Context, mutate fleet.MutateFunc) error {
+	exists := s.claim != nil
+	claim := s.claim
+	if !exists {
+		claim = &fleet.Claim{Features: sets.New[string](), LastRequested: map[string]metav1.Time{}}
+	}
+	changed, err := mutate(claim, exists)
+	if err == nil && changed {
+		s.claim = claim
+	}
+	return err
+}
+
+func (s *fakeSpoke) LicenseSecret() client.ObjectKey {
+	return client.ObjectKey{}
+}
+
+func (s *fakeSpoke) FetchLicenses(_ context.Context, _ client.Reader) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func TestPrune(t *testing.T) {
+	const ttl = 24 * time.Hour
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		lastRequested map[string]time.Time
+		want          []string
+	}{
+		{
+			name:          "expired feature removed",
+			lastRequested: map[string]time.Time{"kubedb": now.Add(-ttl - time.Minute), "stash": now},
+			want:          []string{"stash"},
+		},
+		{
+			name:          "recently requested feature kept",
+			lastRequested: map[string]time.Time{"kubedb": now.Add(-ttl + time.Hour)},
+			want:          []string{"kubedb"},
+		},
+		{
+			name:          "untracked feature kept",
+			lastRequested: map[string]time.Time{"kubedb": {}},
+			want:          []string{"kubedb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := &fleet.Claim{Features: sets.New[string](), LastRequested: map[string]metav1.Time{}}
+			for feature, at := range tt.lastRequested {
+				claim.Features.Insert(feature)
+				if !at.IsZero() {
+					claim.LastRequested[feature] = metav1.NewTime(at)
+				}
+			}
+			spoke := &fakeSpoke{claim: claim}
+
+			p := &Pruner{Spoke: spoke, TTL: ttl}
+			if err := p.prune(context.TODO()); err != nil {
+				t.Fatal(err)
+			}
+			if got := sets.List(spoke.claim.Features); !sets.New(got...).Equal(sets.New(tt.want...)) {
+				t.Errorf("claimed features = %v, want %v", got, tt.want)
+			}
+			for _, feature := range tt.want {
+				if _, found := spoke.claim.LastRequested[feature]; !found {
+					t.Errorf("last requested time of %s is not tracked", feature)
+				}
+			}
+		})
+	}
+}
+
+func TestEnqueueKeepsFeatureFromPruning(t *testing.T) {
+	const ttl = MinFeatureTTL
+	stale := metav1.NewTime(time.Now().Add(-ttl + time.Minute))
+	spoke := &fakeSpoke{claim: &fleet.Claim{
+		Features:      sets.New("kubedb"),
+		LastRequested: map[string]metav1.Time{"kubedb": stale},
+	}}
+
+	// a request for a claimed feature refreshes its last requested time
+	u := NewUpdater(spoke)
+	u.Enqueue([]string{"kubedb"}, false)
+	if err := u.flush(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if got := spoke.claim.LastRequested["kubedb"]; !stale.Before(&got) {
+		t.Fatalf("last requested = %v, want after %v", got, stale)
+	}
+
+	p := &Pruner{Spoke: spoke, TTL: ttl}
+	if err := p.prune(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if !spoke.claim.Features.Has("kubedb") {
+		t.Error("requested feature was pruned")
+	}
+}
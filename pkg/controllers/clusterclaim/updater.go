@@ -0,0 +1,168 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterclaim
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// touchInterval limits how often the last requested time of a feature is written to the claim.
+	touchInterval = time.Hour
+	// MinFeatureTTL is the shortest TTL of unused features, as the last requested time
+	// of features in use is only written every touchInterval.
+	MinFeatureTTL = 2 * touchInterval
+	// batchDelay collects requests arriving close together into a single patch.
+	batchDelay = time.Second
+	// retryDelay is the wait before pending changes are applied again after a failure.
+	retryDelay = 10 * time.Second
+)
+
+// Updater applies license requests to the claim of the cluster in the background.
+// LicenseRequests only enqueue their features, so that concurrent requests
+// neither race on the claim nor wait for the apiserver.
+type Updater struct {
+	Spoke fleet.Spoke
+
+	mu        sync.Mutex
+	additions sets.Set[string]
+	requested map[string]metav1.Time // feature -> last request time
+	notify    chan struct{}
+}
+
+var _ manager.LeaderElectionRunnable = &Updater{}
+
+func NewUpdater(spoke fleet.Spoke) *Updater {
+	return &Updater{
+		Spoke:     spoke,
+		additions: sets.New[string](),
+		requested: map[string]metav1.Time{},
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// SetupWithManager adds the updater to the spoke manager.
+func (u *Updater) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(u)
+}
+
+func (u *Updater) NeedLeaderElection() bool {
+	return false
+}
+
+// Enqueue records a license request for the features. If add is true, features
+// missing from the claim are added, so that the hub acquires licenses for them.
+func (u *Updater) Enqueue(features []string, add bool) {
+	now := metav1.Now()
+
+	u.mu.Lock()
+	for _, feature := range features {
+		u.requested[feature] = now
+		if add {
+			u.additions.Insert(feature)
+		}
+	}
+	u.mu.Unlock()
+
+	u.signal()
+}
+
+func (u *Updater) signal() {
+	select {
+	case u.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (u *Updater) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-u.notify:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(batchDelay):
+		}
+
+		if err := u.flush(ctx); err != nil {
+			klog.ErrorS(err, "failed to update license claim")
+			time.AfterFunc(retryDelay, u.signal)
+		}
+	}
+}
+
+func (u *Updater) flush(ctx context.Context) error {
+	u.mu.Lock()
+	additions, requested := u.additions, u.requested
+	u.additions, u.requested = sets.New[string](), map[string]metav1.Time{}
+	u.mu.Unlock()
+
+	if len(requested) == 0 {
+		return nil
+	}
+	err := u.Spoke.UpdateClaim(ctx, func(claim *fleet.Claim, exists bool) (bool, error) {
+		return applyRequests(claim, exists, additions, requested), nil
+	})
+	if err != nil {
+		// keep the changes for the next attempt, along with requests enqueued meanwhile
+		u.mu.Lock()
+		u.additions = u.additions.Union(additions)
+		for feature, t := range requested {
+			if cur, found := u.requested[feature]; !found || cur.Before(&t) {
+				u.requested[feature] = t
+			}
+		}
+		u.mu.Unlock()
+	}
+	return err
+}
+
+func applyRequests(claim *fleet.Claim, exists bool, additions sets.Set[string], requested map[string]metav1.Time) bool {
+	if !exists && additions.Len() == 0 {
+		return false
+	}
+
+	changed := !exists
+	for feature, now := range requested {
+		if !claim.Features.Has(feature) {
+			if !additions.Has(feature) {
+				continue
+			}
+			claim.Features.Insert(feature)
+			changed = true
+		}
+		if t, found := claim.LastRequested[feature]; !found || now.Sub(t.Time) >= touchInterval {
+			claim.LastRequested[feature] = now
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,63 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterclaim
+
+import (
+	"testing"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestApplyRequests(t *testing.T) {
+	now := metav1.Now()
+	claim := &fleet.Claim{
+		Features:      sets.New[string](),
+		LastRequested: map[string]metav1.Time{},
+	}
+
+	if applyRequests(claim, false, sets.New[string](), map[string]metav1.Time{"kubedb": now}) {
+		t.Fatal("touching a missing claim changed it")
+	}
+
+	if !applyRequests(claim, false, sets.New("kubedb", "stash"), map[string]metav1.Time{"kubedb": now, "stash": now}) {
+		t.Fatal("adding features did not change the claim")
+	}
+	if got := sets.List(claim.Features); len(got) != 2 || got[0] != "kubedb" || got[1] != "stash" {
+		t.Errorf("claimed features = %v", got)
+	}
+	if len(claim.LastRequested) != 2 {
+		t.Errorf("last requested = %v", claim.LastRequested)
+	}
+
+	// requests within touchInterval do not rewrite the claim
+	soon := metav1.NewTime(now.Add(time.Minute))
+	if applyRequests(claim, true, sets.New[string](), map[string]metav1.Time{"kubedb": soon, "kubevault": soon}) {
+		t.Fatal("touching recent features changed the claim")
+	}
+
+	later := metav1.NewTime(now.Add(touchInterval))
+	if !applyRequests(claim, true, sets.New[string](), map[string]metav1.Time{"kubedb": later}) {
+		t.Fatal("touching stale features did not change the claim")
+	}
+	if got := claim.LastRequested["kubedb"]; !got.Equal(&later) {
+		t.Errorf("last requested = %v, want %v", got, later)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	// StatusPath is the path of the hub sync status endpoint served by the proxy.
+	StatusPath = "/hubsync"
+	// HealthzPath is the path of the hub sync health check. It is kept out of /healthz,
+	// /livez and /readyz, so the proxy is not restarted while the hub is unreachable.
+	HealthzPath = StatusPath + "/healthz"
+)
+
+const metricsNamespace = "license_proxyserver"
+
+// SyncStatus reports the health of license syncs from the hub.
+type SyncStatus struct {
+	Healthy bool `json:"healthy"`
+	// LastSyncTime is the time licenses were last read from the hub
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// LastErrorTime is the time of the last failed sync
+	LastErrorTime *metav1.Time `json:"lastErrorTime,omitempty"`
+	LastError     string       `json:"lastError,omitempty"`
+	// LicenseSetAge is the time since the served licenses were last confirmed with the hub
+	LicenseSetAge *metav1.Duration `json:"licenseSetAge,omitempty"`
+}
+
+// SyncHealth tracks the licenses syncs from the hub. Licenses keep being served
+// from the local secret and cache while the hub is unreachable, so a failing sync
+// only makes the license set stale.
+type SyncHealth struct {
+	// StaleAfter is the time without a successful sync after which the sync is reported unhealthy
+	StaleAfter time.Duration
+
+	mu            sync.RWMutex
+	started       time.Time
+	lastSyncTime  time.Time
+	lastErrorTime time.Time
+	lastError     error
+}
+
+var (
+	lastSyncTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "hub_last_sync_timestamp_seconds",
+		Help:      "Unix time licenses were last synced from the hub",
+	})
+	syncErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "hub_sync_errors_total",
+		Help:      "Number of failed license syncs from the hub",
+	})
+)
+
+func NewSyncHealth(staleAfter time.Duration) *SyncHealth {
+	h := &SyncHealth{
+		StaleAfter: staleAfter,
+		started:    time.Now(),
+	}
+	legacyregistry.Registerer().MustRegister(
+		lastSyncTimestamp,
+		syncErrors,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "hub_license_set_age_seconds",
+			Help:      "Seconds since the served licenses were last synced from the hub",
+		}, func() float64 {
+			return h.Status().LicenseSetAge.Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "hub_sync_healthy",
+			Help:      "Whether licenses were synced from the hub recently",
+		}, func() float64 {
+			if h.Status().Healthy {
+				return 1
+			}
+			return 0
+		}),
+	)
+	return h
+}
+
+func (h *SyncHealth) RecordSuccess() {
+	now := time.Now()
+	h.mu.Lock()
+	h.lastSyncTime = now
+	h.mu.Unlock()
+	lastSyncTimestamp.Set(float64(now.Unix()))
+}
+
+func (h *SyncHealth) RecordError(err error) {
+	h.mu.Lock()
+	h.lastErrorTime = time.Now()
+	h.lastError = err
+	h.mu.Unlock()
+	syncErrors.Inc()
+}
+
+func (h *SyncHealth) Status() SyncStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	now := time.Now()
+	status := SyncStatus{}
+	// before the first sync, the licenses loaded from the local secret and cache are as old as the process
+	lastSync := h.started
+	if !h.lastSyncTime.IsZero() {
+		lastSync = h.lastSyncTime
+		status.LastSyncTime = &metav1.Time{Time: h.lastSyncTime}
+	}
+	status.LicenseSetAge = &metav1.Duration{Duration: now.Sub(lastSync).Round(time.Second)}
+	status.Healthy = now.Sub(lastSync) < h.StaleAfter
+	if h.lastError != nil {
+		status.LastErrorTime = &metav1.Time{Time: h.lastErrorTime}
+		status.LastError = h.lastError.Error()
+	}
+	return status
+}
+
+// Name implements healthz.HealthChecker. The check fails when licenses were not synced
+// from the hub within StaleAfter. The proxy keeps serving the cached licenses, so the
+// check is served at HealthzPath only.
+func (h *SyncHealth) Name() string {
+	return "hub-sync"
+}
+
+func (h *SyncHealth) Check(_ *http.Request) error {
+	status := h.Status()
+	if status.Healthy {
+		return nil
+	}
+	if status.LastError != "" {
+		return fmt.Errorf("licenses not synced from hub for %s: %s", status.LicenseSetAge.Duration, status.LastError)
+	}
+	return fmt.Errorf("licenses not synced from hub for %s", status.LicenseSetAge.Duration)
+}
+
+// ServeHTTP serves the SyncStatus as JSON.
+func (h *SyncHealth) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Status())
+}
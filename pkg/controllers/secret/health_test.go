@@ -0,0 +1,63 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncHealth(t *testing.T) {
+	// NewSyncHealth registers metrics, which can be done once per process only
+	h := &SyncHealth{StaleAfter: time.Minute, started: time.Now()}
+
+	// licenses loaded on startup are served until the sync is stale
+	if status := h.Status(); !status.Healthy || status.LastSyncTime != nil {
+		t.Errorf("status before the first sync = %+v, want healthy without a sync time", status)
+	}
+	if err := h.Check(nil); err != nil {
+		t.Errorf("Check() before the first sync = %v, want nil", err)
+	}
+
+	h.started = time.Now().Add(-2 * time.Minute)
+	if status := h.Status(); status.Healthy {
+		t.Error("status without a sync since StaleAfter is healthy")
+	}
+	h.RecordError(errors.New("hub unreachable"))
+	status := h.Status()
+	if status.Healthy || status.LastError != "hub unreachable" || status.LastErrorTime == nil {
+		t.Errorf("status after a failed sync = %+v, want unhealthy with the last error", status)
+	}
+	if err := h.Check(nil); err == nil || !strings.Contains(err.Error(), "hub unreachable") {
+		t.Errorf("Check() = %v, want an error with the last sync error", err)
+	}
+
+	h.RecordSuccess()
+	status = h.Status()
+	if !status.Healthy || status.LastSyncTime == nil || status.LicenseSetAge.Duration > time.Second {
+		t.Errorf("status after a sync = %+v, want healthy with a fresh license set", status)
+	}
+	if err := h.Check(nil); err != nil {
+		t.Errorf("Check() after a sync = %v, want nil", err)
+	}
+	// the last error is kept for the status endpoint
+	if status.LastError == "" {
+		t.Error("last error is dropped after a successful sync")
+	}
+}
@@ -18,16 +18,22 @@ package secret
 
 import (
 	"context"
-	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"time"
 
 	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
 	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
-	verifier "go.bytebuilders.dev/license-verifier"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
 
 	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	kutil "kmodules.xyz/client-go"
 	cu "kmodules.xyz/client-go/client"
@@ -35,34 +41,37 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 type LicenseSyncer struct {
-	HubClient   client.Client
+	// HubReader reads the hub license secret directly, so that a sync
+	// from the hub manager cache is not mistaken for a healthy hub connection
+	HubReader   client.Reader
 	SpokeClient client.Client
+	Fleet       fleet.Spoke
 
 	ClusterID string
-	CaCert    *x509.Certificate
+	CABundle  *trust.Bundle
 	R         *storage.LicenseRegistry
+
+	Health       *SyncHealth
+	ResyncPeriod time.Duration
+
+	// synced holds the IDs of the licenses last synced from the hub
+	synced sets.Set[string]
 }
 
-func (r *LicenseSyncer) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+// Sync status of the entries of the hub license secret, recorded in the spoke license secret.
+const (
+	EntrySynced  = "Synced"
+	EntryExpired = "Expired"
+)
+
+func (r *LicenseSyncer) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Start reconciling")
 
-	// get hub cluster licenses secret
-	src := core.Secret{}
-	err := r.HubClient.Get(ctx, request.NamespacedName, &src)
-	if err != nil {
-		return reconcile.Result{}, err
-	}
-
-	for _, entry := range src.Data {
-		if err := r.addLicense(entry); err != nil {
-			return reconcile.Result{}, err
-		}
-	}
-
 	// get spoke cluster license secret
 	dst := core.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -70,19 +79,137 @@ func (r *LicenseSyncer) Reconcile(ctx context.Context, request reconcile.Request
 			Namespace: common.Namespace(),
 		},
 	}
+	if r.synced == nil {
+		// licenses synced before a restart were loaded from the spoke secret
+		var err error
+		r.synced, err = r.syncedLicenses(ctx, client.ObjectKeyFromObject(&dst))
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// get hub cluster licenses
+	licenses, err := r.Fleet.FetchLicenses(ctx, r.HubReader)
+	if err != nil {
+		r.Health.RecordError(err)
+		return reconcile.Result{}, err
+	}
+	if licenses == nil {
+		logger.Info("hub license secret not found, removing synced licenses")
+	}
+
+	data, status := r.sync(licenses)
+
+	statusBytes, err := json.Marshal(status)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
 	kt, err := cu.CreateOrPatch(ctx, r.SpokeClient, &dst, func(obj client.Object, createOp bool) client.Object {
 		in := obj.(*core.Secret)
-		in.Data = src.Data
+		in.Data = data
+		if in.Annotations == nil {
+			in.Annotations = map[string]string{}
+		}
+		in.Annotations[common.LicenseSyncStatusAnnotation] = string(statusBytes)
 		return in
 	})
 	if err != nil {
+		r.Health.RecordError(err)
 		return reconcile.Result{}, err
 	}
 	if kt != kutil.VerbUnchanged {
 		logger.Info(fmt.Sprintf("%s secret %s/%s", kt, dst.Namespace, dst.Name))
 	}
+	r.Health.RecordSuccess()
 
-	return reconcile.Result{}, nil
+	// resync periodically to confirm the licenses with the hub
+	return reconcile.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// sync adds the valid hub licenses to the registry and removes the licenses deleted on the hub.
+// It returns the entries to write to the spoke license secret along with their sync status.
+//
+// Entries are keyed by license ID along with a feature index,
+// or by plan name in secrets written by older managers.
+// Invalid entries are skipped, so that one bad entry does not block the rest.
+func (r *LicenseSyncer) sync(licenses map[string][]byte) (map[string][]byte, map[string]string) {
+	data := map[string][]byte{}
+	status := map[string]string{}
+	// parsed holds every license found on the hub, written holds the ones copied to the spoke
+	parsed := sets.New[string]()
+	written := sets.New[string]()
+	for key, entry := range licenses {
+		if key == common.LicenseIndexKey {
+			continue
+		}
+		license, err := r.addLicense(entry)
+		if err != nil {
+			klog.ErrorS(err, "skipping invalid license", "key", key)
+			status[key] = err.Error()
+			continue
+		}
+		parsed.Insert(license.ID)
+		if license.Status != v1alpha1.LicenseActive {
+			status[key] = EntryExpired
+			continue
+		}
+		data[key] = entry
+		status[key] = EntrySynced
+		written.Insert(license.ID)
+	}
+	if index, found := licenses[common.LicenseIndexKey]; found {
+		data[common.LicenseIndexKey] = filterIndex(index, written)
+	}
+
+	// mirror licenses removed on the hub
+	for _, id := range sets.List(r.synced.Difference(parsed)) {
+		if r.R.Remove(id) {
+			klog.InfoS("removed license deleted on hub", "licenseID", id)
+		}
+	}
+	r.synced = parsed
+
+	return data, status
+}
+
+// syncedLicenses returns the IDs of the licenses in the spoke license secret.
+func (r *LicenseSyncer) syncedLicenses(ctx context.Context, key client.ObjectKey) (sets.Set[string], error) {
+	ids := sets.New[string]()
+	var sec core.Secret
+	err := r.SpokeClient.Get(ctx, key, &sec)
+	if apierrors.IsNotFound(err) {
+		return ids, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for key, entry := range sec.Data {
+		if key == common.LicenseIndexKey {
+			continue
+		}
+		if license, _, err := r.CABundle.ParseLicense(r.ClusterID, entry); license.ID != "" {
+			ids.Insert(license.ID)
+		} else if err != nil {
+			klog.ErrorS(err, "failed to parse license in spoke secret", "key", key)
+		}
+	}
+	return ids, nil
+}
+
+// filterIndex drops the features of skipped licenses from the feature -> license ID index.
+func filterIndex(data []byte, ids sets.Set[string]) []byte {
+	var index map[string]string
+	if err := json.Unmarshal(data, &index); err != nil {
+		klog.ErrorS(err, "failed to parse license index")
+		return data
+	}
+	maps.DeleteFunc(index, func(_ string, id string) bool {
+		return !ids.Has(id)
+	})
+	out, err := json.Marshal(index)
+	if err != nil {
+		return data
+	}
+	return out
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -90,27 +217,33 @@ func (r *LicenseSyncer) Reconcile(ctx context.Context, request reconcile.Request
 func (r *LicenseSyncer) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&core.Secret{}).
+		// sync once on start, even if the hub license secret does not exist
+		WatchesRawSource(source.Func(func(ctx context.Context, q workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+			q.Add(reconcile.Request{NamespacedName: r.Fleet.LicenseSecret()})
+			return nil
+		})).
 		Complete(r)
 }
 
-func (r *LicenseSyncer) addLicense(data []byte) error {
-	license, err := verifier.ParseLicense(verifier.ParserOptions{
-		ClusterUID: r.ClusterID,
-		CACert:     r.CaCert,
-		License:    data,
-	})
+// addLicense verifies the license and adds it to the registry, unless it expires soon.
+// Licenses that expire soon are returned with the status invalid.
+func (r *LicenseSyncer) addLicense(data []byte) (*v1alpha1.License, error) {
+	license, anchor, err := r.CABundle.ParseLicense(r.ClusterID, data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if time.Until(license.NotAfter.Time) >= storage.MinRemainingLife {
+	if time.Until(license.NotAfter.Time) < storage.MinRemainingLife {
+		license.Status = v1alpha1.LicenseInvalid
+	} else {
 		klog.InfoS("adding license",
 			"licenseID", license.ID,
 			"product", license.ProductLine,
 			"plan", license.PlanName,
 			"expiry", license.NotAfter.UTC().Format(time.RFC822),
+			"anchor", anchor,
 		)
-		r.R.Add(&license, nil)
+		r.R.Add(&license, nil, anchor)
 	}
-	return nil
+	return &license, nil
 }
@@ -0,0 +1,127 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const clusterUID = "8d4bd39a-a1a4-4b2b-9d6b-2f0e5b1c3e7a"
+
+type testLicense struct {
+	id   string
+	data []byte
+}
+
+func newTestSyncer(t *testing.T) (*LicenseSyncer, *devissuer.Issuer) {
+	t.Helper()
+	iss, err := devissuer.New(devissuer.DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := trust.NewBundle(iss.CACertPEM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LicenseSyncer{
+		ClusterID: clusterUID,
+		CABundle:  bundle,
+		R:         storage.NewLicenseRegistry("", storage.MinRemainingLife, nil),
+		synced:    sets.New[string](),
+	}, iss
+}
+
+func issue(t *testing.T, r *LicenseSyncer, iss *devissuer.Issuer, opts devissuer.LicenseOptions, features ...string) testLicense {
+	t.Helper()
+	iss.SetLicenseOptions(opts)
+	data, _, err := iss.Issue(clusterUID, features)
+	if err != nil {
+		t.Fatal(err)
+	}
+	license, _, err := r.CABundle.ParseLicense(clusterUID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testLicense{id: license.ID, data: data}
+}
+
+func TestSyncSkipsInvalidEntries(t *testing.T) {
+	r, iss := newTestSyncer(t)
+	active := issue(t, r, iss, devissuer.DefaultLicenseOptions(), "kubedb")
+	expiring := devissuer.DefaultLicenseOptions()
+	expiring.Duration = time.Minute
+	expired := issue(t, r, iss, expiring, "stash")
+
+	index, err := json.Marshal(map[string]string{"kubedb": active.id, "stash": expired.id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, status := r.sync(map[string][]byte{
+		active.id:              active.data,
+		expired.id:             expired.data,
+		"garbage":              []byte("garbage"),
+		common.LicenseIndexKey: index,
+	})
+
+	if _, found := data[active.id]; !found || len(data) != 2 {
+		t.Errorf("synced entries = %v, want %s and the index", sets.List(sets.KeySet(data)), active.id)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(data[common.LicenseIndexKey], &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["kubedb"] != active.id {
+		t.Errorf("index = %v, want only kubedb -> %s", got, active.id)
+	}
+	if status[active.id] != EntrySynced || status[expired.id] != EntryExpired || status["garbage"] == "" {
+		t.Errorf("status = %v", status)
+	}
+	if _, found := r.R.LicenseForFeature("kubedb"); !found {
+		t.Error("valid license was not added to the registry")
+	}
+}
+
+func TestSyncRemovesLicenseDeletedOnHub(t *testing.T) {
+	r, iss := newTestSyncer(t)
+	kubedb := issue(t, r, iss, devissuer.DefaultLicenseOptions(), "kubedb")
+	stash := issue(t, r, iss, devissuer.DefaultLicenseOptions(), "stash")
+
+	r.sync(map[string][]byte{kubedb.id: kubedb.data, stash.id: stash.data})
+	if _, found := r.R.LicenseForFeature("stash"); !found {
+		t.Fatal("license was not added to the registry")
+	}
+
+	data, _ := r.sync(map[string][]byte{kubedb.id: kubedb.data})
+	if _, found := r.R.LicenseForFeature("stash"); found {
+		t.Error("license removed on hub is still in the registry")
+	}
+	if _, found := r.R.LicenseForFeature("kubedb"); !found {
+		t.Error("license kept on hub was removed from the registry")
+	}
+	if _, found := data[stash.id]; found {
+		t.Error("license removed on hub is still synced")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Reporter periodically publishes the feature usage recorded on the spoke
+// to the usage secret in the cluster namespace on the hub.
+type Reporter struct {
+	HubClient client.Client
+	// HubReader reads the usage secret, as the hub manager only caches the license secret
+	HubReader client.Reader
+
+	ClusterName string
+	ClusterID   string
+	RecordBook  *storage.RecordBook
+	Interval    time.Duration
+}
+
+var _ manager.LeaderElectionRunnable = &Reporter{}
+
+// SetupWithManager adds the reporter to the hub manager.
+func (r *Reporter) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(r)
+}
+
+func (r *Reporter) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *Reporter) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.report(ctx); err != nil {
+			klog.ErrorS(err, "failed to report license usage", "clusterName", r.ClusterName)
+		}
+	}, r.Interval)
+	return nil
+}
+
+func (r *Reporter) report(ctx context.Context) error {
+	report := hubapi.UsageReport{
+		ClusterUID: r.ClusterID,
+		ReportTime: metav1.Now(),
+		Features:   r.RecordBook.Usage(),
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	sec := core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.LicenseUsageSecret,
+			Namespace: r.ClusterName,
+		},
+	}
+	err = r.HubReader.Get(ctx, client.ObjectKeyFromObject(&sec), &sec)
+	if apierrors.IsNotFound(err) {
+		// the manager creates the secret when the addon is enabled; the agent may only update it
+		klog.V(4).InfoS("license usage secret not found", "namespace", sec.Namespace, "name", sec.Name)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// every write requeues the cluster on the hub, so unchanged usage is not reported again
+	if !usageChanged(sec.Data[common.LicenseUsageKey], report) {
+		return nil
+	}
+
+	patch := client.MergeFrom(sec.DeepCopy())
+	sec.Data = map[string][]byte{
+		common.LicenseUsageKey: data,
+	}
+	return r.HubClient.Patch(ctx, &sec, patch)
+}
+
+// usageChanged returns true if the report differs from the reported one, ignoring the report time.
+func usageChanged(reported []byte, report hubapi.UsageReport) bool {
+	var prev hubapi.UsageReport
+	if err := json.Unmarshal(reported, &prev); err != nil {
+		return true
+	}
+	prev.ReportTime = report.ReportTime
+	prevData, err := json.Marshal(prev)
+	if err != nil {
+		return true
+	}
+	data, err := json.Marshal(report)
+	return err != nil || !bytes.Equal(prevData, data)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUsageChanged(t *testing.T) {
+	requested := metav1.NewTime(time.Now().Add(-time.Hour))
+	reported, err := json.Marshal(hubapi.UsageReport{
+		ClusterUID: "uid-1",
+		ReportTime: metav1.NewTime(time.Now().Add(-10 * time.Minute)),
+		Features:   []hubapi.FeatureUsage{{Feature: "kubedb", LastRequestTime: requested}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		reported []byte
+		features []hubapi.FeatureUsage
+		want     bool
+	}{
+		{"nothing reported", nil, nil, true},
+		{"unchanged", reported, []hubapi.FeatureUsage{{Feature: "kubedb", LastRequestTime: requested}}, false},
+		{"requested again", reported, []hubapi.FeatureUsage{{Feature: "kubedb", LastRequestTime: metav1.Now()}}, true},
+		{"new feature", reported, []hubapi.FeatureUsage{
+			{Feature: "kubedb", LastRequestTime: requested},
+			{Feature: "stash", LastRequestTime: requested},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := hubapi.UsageReport{ClusterUID: "uid-1", ReportTime: metav1.Now(), Features: tt.features}
+			if got := usageChanged(tt.reported, report); got != tt.want {
+				t.Errorf("usageChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
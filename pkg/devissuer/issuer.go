@@ -0,0 +1,248 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devissuer
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+
+	"github.com/pkg/errors"
+	"gomodules.xyz/cert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status of the licenses handed out by the Issuer. Anything other than
+// active produces a license that fails verification on the proxy side.
+// Canceled licenses are not supported, as neither the license certificate
+// nor the contract returned by the issuer can carry a canceled state.
+type Status string
+
+const (
+	StatusActive  Status = "active"
+	StatusExpired Status = "expired"
+	StatusInvalid Status = "invalid"
+)
+
+// ParseStatus returns the Status of the given name.
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case StatusActive, StatusExpired, StatusInvalid:
+		return status, nil
+	}
+	return "", fmt.Errorf("unsupported license status %q, must be one of active, expired or invalid", s)
+}
+
+// LicenseOptions decides the shape of the licenses issued.
+type LicenseOptions struct {
+	ProductLine  string
+	TierName     string
+	PlanName     string
+	Duration     time.Duration
+	Status       Status
+	FeatureFlags v1alpha1.FeatureFlags
+}
+
+// Faults are injected into issuer responses before a license is signed.
+type Faults struct {
+	// StatusCode, if non-zero, is returned instead of a license.
+	StatusCode int
+	// Delay is applied before every response.
+	Delay time.Duration
+}
+
+// Issuer signs licenses using a CA it owns. It is meant for local development
+// and tests, never for production use.
+type Issuer struct {
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+
+	m      sync.RWMutex
+	opts   LicenseOptions
+	faults Faults
+}
+
+func DefaultLicenseOptions() LicenseOptions {
+	return LicenseOptions{
+		ProductLine: "kubedb",
+		TierName:    "enterprise",
+		PlanName:    "kubedb-enterprise",
+		Duration:    24 * time.Hour,
+		Status:      StatusActive,
+	}
+}
+
+// New returns an Issuer with a freshly generated CA.
+func New(opts LicenseOptions) (*Issuer, error) {
+	key, err := cert.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := cert.NewSelfSignedCACert(cert.Config{
+		CommonName:   "dev-issuer",
+		Organization: []string{"AppsCode Dev"},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{caCert: caCert, caKey: key, opts: opts}, nil
+}
+
+// NewFromPEM returns an Issuer that signs licenses with an existing CA.
+func NewFromPEM(caCertPEM, caKeyPEM []byte, opts LicenseOptions) (*Issuer, error) {
+	certs, err := cert.ParseCertsPEM(caCertPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA cert")
+	}
+	key, err := cert.ParsePrivateKeyPEM(caKeyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA key")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("CA key of type %T can't sign certificates", key)
+	}
+	return &Issuer{caCert: certs[0], caKey: signer, opts: opts}, nil
+}
+
+func (i *Issuer) CACert() *x509.Certificate {
+	return i.caCert
+}
+
+func (i *Issuer) CACertPEM() []byte {
+	return cert.EncodeCertPEM(i.caCert)
+}
+
+func (i *Issuer) CAKeyPEM() ([]byte, error) {
+	return cert.MarshalPrivateKeyToPEM(i.caKey)
+}
+
+func (i *Issuer) SetLicenseOptions(opts LicenseOptions) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.opts = opts
+}
+
+func (i *Issuer) SetFaults(f Faults) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.faults = f
+}
+
+func (i *Issuer) getOptions() (LicenseOptions, Faults) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	return i.opts, i.faults
+}
+
+// Issue signs a PEM encoded license for the given cluster and features.
+func (i *Issuer) Issue(cluster string, features []string) ([]byte, *v1alpha1.Contract, error) {
+	opts, _ := i.getOptions()
+	return i.issue(opts, cluster, features)
+}
+
+func (i *Issuer) issue(opts LicenseOptions, cluster string, features []string) ([]byte, *v1alpha1.Contract, error) {
+	if cluster == "" {
+		return nil, nil, errors.New("missing cluster")
+	}
+	if len(features) == 0 {
+		return nil, nil, errors.New("missing features")
+	}
+
+	now := time.Now()
+	notBefore := now.Add(-time.Minute)
+	duration := opts.Duration
+	if duration <= 0 {
+		duration = 24 * time.Hour
+	}
+	notAfter := now.Add(duration)
+	switch opts.Status {
+	case StatusExpired:
+		notBefore = now.Add(-2 * duration)
+		notAfter = now.Add(-duration)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	planName := opts.PlanName
+	if planName == "" {
+		planName = strings.Join([]string{opts.ProductLine, opts.TierName}, "-")
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:         cluster,
+			Organization:       features,
+			OrganizationalUnit: []string{planName},
+			Country:            []string{opts.ProductLine},
+			Province:           []string{opts.TierName},
+			Locality:           opts.FeatureFlags.ToSlice(),
+		},
+		DNSNames:       []string{cluster},
+		EmailAddresses: []string{"Dev User <dev@appscode.com>"},
+		NotBefore:      notBefore.UTC(),
+		NotAfter:       notAfter.UTC(),
+		KeyUsage:       x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	signerCert, signerKey := i.caCert, i.caKey
+	if opts.Status == StatusInvalid {
+		// sign with a throwaway CA that nobody trusts
+		key, err := cert.NewPrivateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		signerCert, err = cert.NewSelfSignedCACert(cert.Config{CommonName: "untrusted"}, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		signerKey = key
+	}
+
+	key, err := cert.NewPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, signerCert, key.Public(), signerKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	con := &v1alpha1.Contract{
+		ID:              fmt.Sprintf("dev-%s", serial.String()),
+		StartTimestamp:  metav1.NewTime(notBefore),
+		ExpiryTimestamp: metav1.NewTime(notAfter),
+	}
+	return cert.EncodeCertPEM(crt), con, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devissuer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	verifier "go.bytebuilders.dev/license-verifier"
+	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+	pc "go.bytebuilders.dev/license-verifier/client"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const clusterUID = "8d4bd39a-a1a4-4b2b-9d6b-2f0e5b1c3e7a"
+
+func TestAcquireLicense(t *testing.T) {
+	iss, err := New(DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(iss.Handler())
+	defer srv.Close()
+
+	lc, err := pc.NewClient(srv.URL, "token", clusterUID, nil, false, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status  Status
+		wantErr bool
+	}{
+		{StatusActive, false},
+		{StatusExpired, true},
+		{StatusInvalid, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			opts := DefaultLicenseOptions()
+			opts.Status = tt.status
+			iss.SetLicenseOptions(opts)
+
+			data, con, err := lc.AcquireLicense([]string{"kubedb-ext-stash"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if con == nil {
+				t.Fatal("missing contract")
+			}
+			l, err := verifier.ParseLicense(verifier.ParserOptions{
+				ClusterUID: clusterUID,
+				CACert:     iss.CACert(),
+				License:    data,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLicense() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (l.Status != v1alpha1.LicenseActive || l.PlanName != opts.PlanName) {
+				t.Errorf("unexpected license %+v", l)
+			}
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	iss, err := New(DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(iss.Handler())
+	defer srv.Close()
+
+	lc, err := pc.NewClient(srv.URL, "token", clusterUID, nil, false, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iss.SetFaults(Faults{StatusCode: http.StatusServiceUnavailable})
+	_, _, err = lc.AcquireLicense([]string{"kubedb-ext-stash"})
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("expected service unavailable error, got %v", err)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for _, s := range []string{"active", "expired", "invalid"} {
+		if status, err := ParseStatus(s); err != nil || string(status) != s {
+			t.Errorf("ParseStatus(%s) = %s, %v", s, status, err)
+		}
+	}
+	if _, err := ParseStatus("canceled"); err == nil {
+		t.Error("ParseStatus(canceled) returned no error")
+	}
+}
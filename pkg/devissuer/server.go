@@ -0,0 +1,97 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devissuer
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"time"
+
+	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+	"go.bytebuilders.dev/license-verifier/info"
+
+	"k8s.io/klog/v2"
+)
+
+const CACertPath = "/certificates/ca.crt"
+
+type acquireRequest struct {
+	Cluster  string   `json:"cluster"`
+	Features []string `json:"features"`
+}
+
+type acquireResponse struct {
+	Contract *v1alpha1.Contract `json:"contract,omitempty"`
+	License  []byte             `json:"license"`
+}
+
+// Handler serves the license issue API used by pc.Client.AcquireLicense and
+// the CA certificate of the Issuer.
+func (i *Issuer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path.Join("/", info.LicenseIssuerAPIPath), i.serveIssue)
+	mux.HandleFunc(CACertPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		_, _ = w.Write(i.CACertPEM())
+	})
+	return mux
+}
+
+func (i *Issuer) serveIssue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts, faults := i.getOptions()
+	if faults.Delay > 0 {
+		select {
+		case <-time.After(faults.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if faults.StatusCode != 0 {
+		http.Error(w, http.StatusText(faults.StatusCode), faults.StatusCode)
+		return
+	}
+
+	var req acquireRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, con, err := i.issue(opts, req.Cluster, req.Features)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	klog.InfoS("issued license",
+		"cluster", req.Cluster,
+		"features", req.Features,
+		"plan", opts.PlanName,
+		"status", opts.Status,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(acquireResponse{
+		Contract: con,
+		License:  data,
+	})
+}
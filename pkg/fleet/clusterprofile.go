@@ -0,0 +1,177 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fleet
+
+import (
+	"context"
+
+	"go.bytebuilders.dev/license-verifier/info"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterProfileGVK is the SIG-Multicluster ClusterProfile API. It is accessed as unstructured,
+// as the API is not vendored.
+var ClusterProfileGVK = schema.GroupVersionKind{
+	Group:   "multicluster.x-k8s.io",
+	Version: "v1alpha1",
+	Kind:    "ClusterProfile",
+}
+
+// ocmClusterManager is the cluster manager name of ClusterProfiles created by OCM for ManagedClusters.
+const ocmClusterManager = "open-cluster-management"
+
+// ClusterProfileMember is the MemberLabel value of the ConfigMaps holding the claims of ClusterProfiles.
+const ClusterProfileMember = "clusterprofile"
+
+// ClusterProfileSpoke reports the features wanted by the cluster in a ConfigMap named after the cluster
+// in the namespace of its ClusterProfile on the hub, and fetches licenses from the same namespace.
+// The hub credentials need to allow writing the ConfigMap and reading the license secret.
+type ClusterProfileSpoke struct {
+	// HubKubeconfig is the path of the hub kubeconfig. It is read on every update,
+	// so that rotated credentials are picked up.
+	HubKubeconfig string
+	// Namespace is the namespace of the ClusterProfile of the cluster on the hub
+	Namespace   string
+	ClusterName string
+	ClusterID   string
+}
+
+var _ Spoke = &ClusterProfileSpoke{}
+
+func (s *ClusterProfileSpoke) UpdateClaim(ctx context.Context, mutate MutateFunc) error {
+	return updateMemberConfigMap(ctx, s.HubKubeconfig, s.Namespace, s.ClusterName, s.ClusterID, ClusterProfileMember, mutate)
+}
+
+func (s *ClusterProfileSpoke) LicenseSecret() client.ObjectKey {
+	return client.ObjectKey{Name: LicenseSecretName(s.ClusterName), Namespace: s.Namespace}
+}
+
+func (s *ClusterProfileSpoke) FetchLicenses(ctx context.Context, hub client.Reader) (map[string][]byte, error) {
+	return fetchLicenses(ctx, hub, s.LicenseSecret())
+}
+
+// ClusterProfileHub serves ClusterProfiles. The cluster UID and the wanted features are read from
+// the claim ConfigMap named after the ClusterProfile in its namespace, which is written by the
+// license proxy of the cluster. Licenses are published in the namespace of the ClusterProfile.
+type ClusterProfileHub struct {
+	Client client.Client
+	// SkipOCM skips ClusterProfiles of OCM ManagedClusters, which are served by the OCM backend
+	SkipOCM bool
+}
+
+var _ Hub = &ClusterProfileHub{}
+
+func newClusterProfile() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(ClusterProfileGVK)
+	return obj
+}
+
+func (h *ClusterProfileHub) MemberType() client.Object {
+	return newClusterProfile()
+}
+
+func (h *ClusterProfileHub) IsMember(obj client.Object) bool {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+	if h.SkipOCM {
+		manager, _, _ := unstructured.NestedString(u.Object, "spec", "clusterManager", "name")
+		return manager != ocmClusterManager
+	}
+	return true
+}
+
+func (h *ClusterProfileHub) ListMembers(ctx context.Context) ([]Member, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ClusterProfileGVK.GroupVersion().WithKind(ClusterProfileGVK.Kind + "List"))
+	if err := h.Client.List(ctx, list); err != nil {
+		return nil, err
+	}
+	var claims core.ConfigMapList
+	if err := h.Client.List(ctx, &claims, client.MatchingLabels{MemberLabel: ClusterProfileMember}); err != nil {
+		return nil, err
+	}
+	byKey := map[client.ObjectKey]*core.ConfigMap{}
+	for i := range claims.Items {
+		byKey[client.ObjectKeyFromObject(&claims.Items[i])] = &claims.Items[i]
+	}
+	members := make([]Member, 0, len(list.Items))
+	for i := range list.Items {
+		if h.IsMember(&list.Items[i]) {
+			members = append(members, clusterProfileMember(&list.Items[i], byKey[client.ObjectKeyFromObject(&list.Items[i])]))
+		}
+	}
+	return members, nil
+}
+
+func (h *ClusterProfileHub) GetMember(ctx context.Context, key client.ObjectKey) (*Member, error) {
+	obj := newClusterProfile()
+	if err := h.Client.Get(ctx, key, obj); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !h.IsMember(obj) {
+		return nil, nil
+	}
+	claim := &core.ConfigMap{}
+	if err := h.Client.Get(ctx, key, claim); apierrors.IsNotFound(err) {
+		claim = nil
+	} else if err != nil {
+		return nil, err
+	}
+	member := clusterProfileMember(obj, claim)
+	return &member, nil
+}
+
+// ClaimMemberKey returns the key of the ClusterProfile, if the ConfigMap holds the claim of a ClusterProfile.
+func ClaimMemberKey(obj client.Object) (client.ObjectKey, bool) {
+	if obj.GetLabels()[MemberLabel] != ClusterProfileMember {
+		return client.ObjectKey{}, false
+	}
+	return client.ObjectKeyFromObject(obj), true
+}
+
+func (h *ClusterProfileHub) LicenseSecret(member *Member) client.ObjectKey {
+	return client.ObjectKey{Name: LicenseSecretName(member.Name), Namespace: member.Object.GetNamespace()}
+}
+
+// ClusterProfileKey returns the member key of a ClusterProfile.
+func ClusterProfileKey(namespace, name string) string {
+	return BackendClusterProfile + "." + namespace + "." + name
+}
+
+// clusterProfileMember returns the member of a ClusterProfile. claim is nil
+// if the license proxy of the cluster has not claimed any features yet.
+func clusterProfileMember(obj *unstructured.Unstructured, claim *core.ConfigMap) Member {
+	member := Member{
+		Name: obj.GetName(),
+		// ClusterProfiles are namespaced and served next to the members of another backend
+		Key:    ClusterProfileKey(obj.GetNamespace(), obj.GetName()),
+		Object: obj,
+	}
+	if claim != nil && claim.Labels[MemberLabel] == ClusterProfileMember {
+		member.UID = claim.Data[ClusterUIDKey]
+		member.Features = info.ParseFeatures(claim.Data[FeaturesKey])
+	}
+	return member
+}
@@ -0,0 +1,154 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fleet
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestClusterProfile(manager string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":      "prod",
+			"namespace": "fleet",
+		},
+		"spec": map[string]any{
+			"clusterManager": map[string]any{"name": manager},
+		},
+	}}
+	obj.SetGroupVersionKind(ClusterProfileGVK)
+	return obj
+}
+
+func TestClusterProfileMember(t *testing.T) {
+	obj := newTestClusterProfile(ocmClusterManager)
+
+	member := clusterProfileMember(obj, nil)
+	if member.Name != "prod" || member.UID != "" || len(member.Features) != 0 {
+		t.Errorf("member without claim = %+v", member)
+	}
+	if member.Key != "clusterprofile.fleet.prod" {
+		t.Errorf("member key = %s", member.Key)
+	}
+
+	hub := &ClusterProfileHub{}
+	if key := hub.LicenseSecret(&member); key.Namespace != "fleet" || key.Name != "prod-licenses" {
+		t.Errorf("license secret = %s", key)
+	}
+	if !hub.IsMember(obj) {
+		t.Error("ClusterProfile is not a member")
+	}
+	hub.SkipOCM = true
+	if hub.IsMember(obj) {
+		t.Error("ClusterProfile of an OCM ManagedCluster is a member")
+	}
+}
+
+func TestClusterProfileClaim(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	s.AddKnownTypeWithName(ClusterProfileGVK, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(ClusterProfileGVK.GroupVersion().WithKind(ClusterProfileGVK.Kind+"List"), &unstructured.UnstructuredList{})
+	kc := fake.NewClientBuilder().WithScheme(s).WithObjects(newTestClusterProfile("other")).Build()
+
+	orig := newHubClient
+	newHubClient = func(string) (client.Client, error) {
+		return kc, nil
+	}
+	defer func() {
+		newHubClient = orig
+	}()
+
+	ctx := context.TODO()
+	hub := &ClusterProfileHub{Client: kc, SkipOCM: true}
+	member, err := hub.GetMember(ctx, client.ObjectKey{Namespace: "fleet", Name: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member == nil || member.UID != "" {
+		t.Fatalf("member before the claim = %+v", member)
+	}
+
+	spoke := &ClusterProfileSpoke{Namespace: "fleet", ClusterName: "prod", ClusterID: "uid-1"}
+	err = spoke.UpdateClaim(ctx, func(claim *Claim, _ bool) (bool, error) {
+		claim.Features.Insert("kubedb", "stash")
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	member, err = hub.GetMember(ctx, client.ObjectKey{Namespace: "fleet", Name: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member == nil || member.UID != "uid-1" || !slices.Equal(member.Features, []string{"kubedb", "stash"}) {
+		t.Errorf("member = %+v", member)
+	}
+	if hub.LicenseSecret(member) != spoke.LicenseSecret() {
+		t.Errorf("hub publishes licenses in %s, spoke fetches them from %s", hub.LicenseSecret(member), spoke.LicenseSecret())
+	}
+
+	members, err := hub.ListMembers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UID != "uid-1" {
+		t.Errorf("members = %+v", members)
+	}
+
+	var cm core.ConfigMap
+	if err := kc.Get(ctx, client.ObjectKey{Namespace: "fleet", Name: "prod"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	if key, found := ClaimMemberKey(&cm); !found || key != client.ObjectKeyFromObject(member.Object) {
+		t.Errorf("claim member key = %s, %v", key, found)
+	}
+}
+
+func TestInClusterSet(t *testing.T) {
+	profile := newClusterProfile()
+	profile.SetNamespace("prod")
+	if !InClusterSet(profile, "prod") || InClusterSet(profile, "dev") {
+		t.Error("ClusterProfile without the ClusterSet label is not in the ClusterSet of its namespace")
+	}
+	profile.SetLabels(map[string]string{ClusterSetLabel: "dev"})
+	if InClusterSet(profile, "prod") || !InClusterSet(profile, "dev") {
+		t.Error("ClusterProfile is not in the ClusterSet of its label")
+	}
+
+	cm := &core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "prod"}}
+	if InClusterSet(cm, "prod") {
+		t.Error("member ConfigMap without the ClusterSet label is in a ClusterSet")
+	}
+	cm.Labels = map[string]string{ClusterSetLabel: "prod"}
+	if !InClusterSet(cm, "prod") {
+		t.Error("member ConfigMap is not in the ClusterSet of its label")
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fleet connects the license manager on the hub with the license proxies
+// on member clusters. Member clusters report the features they want licenses for,
+// the manager enumerates the member clusters and publishes the licenses granted
+// to each of them in a hub secret, which the proxies fetch.
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-verifier/info"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Supported fleet backends.
+const (
+	// BackendOCM uses Open Cluster Management: features are reported as a ClusterClaim,
+	// members are ManagedClusters and licenses are published in the cluster namespaces.
+	BackendOCM = "ocm"
+	// BackendShared uses plain ConfigMaps and Secrets in a namespace on the hub shared by all members.
+	BackendShared = "shared"
+	// BackendClusterProfile is used by license proxies on clusters represented by a SIG-Multicluster
+	// ClusterProfile on the hub. Features are reported in a ConfigMap next to the ClusterProfile
+	// and licenses are published in the namespace of the ClusterProfile. The manager serves
+	// ClusterProfiles along with any backend.
+	BackendClusterProfile = "clusterprofile"
+)
+
+// Claim is the set of features a member cluster wants licenses for.
+type Claim struct {
+	Features sets.Set[string]
+	// LastRequested is the last time a license was requested for each feature
+	LastRequested map[string]metav1.Time
+}
+
+// MutateFunc changes the claim of the cluster and returns true if it changed.
+// exists is false if the cluster has not claimed any features yet.
+type MutateFunc func(claim *Claim, exists bool) (bool, error)
+
+// Spoke is used by the license proxy on a member cluster.
+type Spoke interface {
+	// UpdateClaim reports the features the cluster wants licenses for.
+	UpdateClaim(ctx context.Context, mutate MutateFunc) error
+	// LicenseSecret returns the hub secret licenses granted to the cluster are published in.
+	LicenseSecret() client.ObjectKey
+	// FetchLicenses returns the licenses granted to the cluster, keyed as in the license secret.
+	// It returns no licenses if none were granted yet.
+	FetchLicenses(ctx context.Context, hub client.Reader) (map[string][]byte, error)
+}
+
+// Member is a cluster served by the license manager.
+type Member struct {
+	Name string
+	// Key identifies the member among the members of all backends.
+	// It names the LicenseInventory of the member and labels its metrics.
+	Key      string
+	UID      string
+	Features []string
+	// Object represents the member on the hub. License state of the member
+	// is cleaned up using a finalizer on it and events are recorded for it.
+	Object client.Object
+}
+
+// Hub is used by the license manager on the hub.
+type Hub interface {
+	// MemberType returns an empty object of the type representing member clusters on the hub.
+	MemberType() client.Object
+	// IsMember returns true if obj, of the member type, represents a member cluster.
+	IsMember(obj client.Object) bool
+	// ListMembers enumerates the member clusters.
+	ListMembers(ctx context.Context) ([]Member, error)
+	// GetMember returns the member represented by the object with the key.
+	// It returns nil if the object does not exist.
+	GetMember(ctx context.Context, key client.ObjectKey) (*Member, error)
+	// LicenseSecret returns the hub secret licenses granted to a member are published in.
+	LicenseSecret(member *Member) client.ObjectKey
+}
+
+// ClusterSetLabel is the SIG-Multicluster label naming the ClusterSet of a ClusterProfile.
+// Members of the shared backend are added to a ClusterSet using the same label.
+const ClusterSetLabel = "x-k8s.io/cluster-set"
+
+// InClusterSet returns true if a member of a backend other than OCM belongs to the ClusterSet.
+// A ClusterProfile without the ClusterSet label belongs to the ClusterSet named after its namespace.
+func InClusterSet(obj client.Object, name string) bool {
+	if set, found := obj.GetLabels()[ClusterSetLabel]; found {
+		return set == name
+	}
+	return obj.GetObjectKind().GroupVersionKind() == ClusterProfileGVK && obj.GetNamespace() == name
+}
+
+// conflictBackoff is used to retry claim updates that conflict with concurrent writers.
+var conflictBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// claimCodec converts between a claim and the object storing it.
+type claimCodec[T client.Object] struct {
+	newObject func() T
+	decode    func(obj T) *Claim
+	encode    func(claim *Claim, obj T) error
+}
+
+// updateClaim applies mutate to the claim stored in an object using a merge patch guarded
+// by the resource version, and retries with the latest object on conflict.
+func updateClaim[T client.Object](ctx context.Context, kc client.Client, codec claimCodec[T], mutate MutateFunc) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(conflictBackoff, func() (bool, error) {
+		lastErr = tryUpdateClaim(ctx, kc, codec, mutate)
+		switch {
+		case lastErr == nil:
+			return true, nil
+		case apierrors.IsConflict(lastErr), apierrors.IsAlreadyExists(lastErr):
+			return false, nil
+		default:
+			return false, lastErr
+		}
+	})
+	if wait.Interrupted(err) {
+		return lastErr
+	}
+	return err
+}
+
+func tryUpdateClaim[T client.Object](ctx context.Context, kc client.Client, codec claimCodec[T], mutate MutateFunc) error {
+	obj := codec.newObject()
+	err := kc.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+	exists := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	orig := obj.DeepCopyObject().(client.Object)
+	claim := codec.decode(obj)
+	changed, err := mutate(claim, exists)
+	if err != nil || !changed {
+		return err
+	}
+	if err := codec.encode(claim, obj); err != nil {
+		return err
+	}
+	if !exists {
+		return kc.Create(ctx, obj)
+	}
+	return kc.Patch(ctx, obj, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{}))
+}
+
+// decodeClaim reads a claim from the comma separated features and the last requested annotation.
+func decodeClaim(obj client.Object, features string) *Claim {
+	claim := &Claim{
+		Features:      sets.New[string](info.ParseFeatures(features)...),
+		LastRequested: map[string]metav1.Time{},
+	}
+	if v, found := obj.GetAnnotations()[common.ClusterClaimLastRequestedAnnotation]; found {
+		if err := json.Unmarshal([]byte(v), &claim.LastRequested); err != nil {
+			klog.ErrorS(err, "failed to parse last requested times of claim", "name", obj.GetName())
+		}
+	}
+	return claim
+}
+
+// encodeClaim writes the last requested annotation of the claim and returns its comma separated features.
+func encodeClaim(claim *Claim, obj client.Object) (string, error) {
+	for feature := range claim.LastRequested {
+		if !claim.Features.Has(feature) {
+			delete(claim.LastRequested, feature)
+		}
+	}
+	data, err := json.Marshal(claim.LastRequested)
+	if err != nil {
+		return "", err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.ClusterClaimLastRequestedAnnotation] = string(data)
+	obj.SetAnnotations(annotations)
+	return strings.Join(sets.List(claim.Features), ","), nil
+}
+
+// fetchLicenses reads the licenses from a hub secret.
+func fetchLicenses(ctx context.Context, hub client.Reader, key client.ObjectKey) (map[string][]byte, error) {
+	var sec core.Secret
+	err := hub.Get(ctx, key, &sec)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", key, err)
+	}
+	if sec.Data == nil {
+		return map[string][]byte{}, nil
+	}
+	return sec.Data, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fleet
+
+import (
+	"context"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-verifier/info"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OCMSpoke reports the features wanted by the cluster in the license ClusterClaim,
+// which the OCM registration agent syncs to the ManagedCluster on the hub.
+type OCMSpoke struct {
+	// Client is the client of the member cluster
+	Client      client.Client
+	ClusterName string
+}
+
+var _ Spoke = &OCMSpoke{}
+
+var clusterClaimCodec = claimCodec[*clusterv1alpha1.ClusterClaim]{
+	newObject: func() *clusterv1alpha1.ClusterClaim {
+		return &clusterv1alpha1.ClusterClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: common.ClusterClaimLicense,
+			},
+		}
+	},
+	decode: func(ca *clusterv1alpha1.ClusterClaim) *Claim {
+		return decodeClaim(ca, ca.Spec.Value)
+	},
+	encode: func(claim *Claim, ca *clusterv1alpha1.ClusterClaim) error {
+		value, err := encodeClaim(claim, ca)
+		ca.Spec.Value = value
+		return err
+	},
+}
+
+func (s *OCMSpoke) UpdateClaim(ctx context.Context, mutate MutateFunc) error {
+	return updateClaim(ctx, s.Client, clusterClaimCodec, mutate)
+}
+
+func (s *OCMSpoke) LicenseSecret() client.ObjectKey {
+	return client.ObjectKey{Name: common.LicenseSecret, Namespace: s.ClusterName}
+}
+
+func (s *OCMSpoke) FetchLicenses(ctx context.Context, hub client.Reader) (map[string][]byte, error) {
+	return fetchLicenses(ctx, hub, s.LicenseSecret())
+}
+
+// OCMHub serves ManagedClusters, which carry the cluster UID and the wanted
+// features as ClusterClaims. Licenses are published in the cluster namespaces.
+type OCMHub struct {
+	Client client.Client
+}
+
+var _ Hub = &OCMHub{}
+
+func (h *OCMHub) MemberType() client.Object {
+	return &clusterv1.ManagedCluster{}
+}
+
+func (h *OCMHub) IsMember(_ client.Object) bool {
+	return true
+}
+
+func (h *OCMHub) ListMembers(ctx context.Context) ([]Member, error) {
+	var list clusterv1.ManagedClusterList
+	if err := h.Client.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	members := make([]Member, 0, len(list.Items))
+	for i := range list.Items {
+		members = append(members, managedClusterMember(&list.Items[i]))
+	}
+	return members, nil
+}
+
+func (h *OCMHub) GetMember(ctx context.Context, key client.ObjectKey) (*Member, error) {
+	var cluster clusterv1.ManagedCluster
+	if err := h.Client.Get(ctx, key, &cluster); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	member := managedClusterMember(&cluster)
+	return &member, nil
+}
+
+func (h *OCMHub) LicenseSecret(member *Member) client.ObjectKey {
+	return client.ObjectKey{Name: common.LicenseSecret, Namespace: member.Name}
+}
+
+func managedClusterMember(cluster *clusterv1.ManagedCluster) Member {
+	member := Member{
+		Name:   cluster.Name,
+		Key:    cluster.Name,
+		Object: cluster,
+	}
+	for _, claim := range cluster.Status.ClusterClaims {
+		switch claim.Name {
+		case common.ClusterClaimClusterID:
+			member.UID = claim.Value
+		case common.ClusterClaimLicense:
+			member.Features = info.ParseFeatures(claim.Value)
+		}
+	}
+	return member
+}
@@ -0,0 +1,176 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fleet
+
+import (
+	"context"
+
+	"go.bytebuilders.dev/license-verifier/info"
+
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// In the shared backend, each member cluster is represented by a ConfigMap named after
+// the cluster in the shared hub namespace. The licenses granted to the cluster are
+// published in the Secret named LicenseSecretName(cluster) in the same namespace.
+const (
+	// MemberLabel marks the ConfigMaps representing member clusters. ConfigMaps holding the
+	// claims of ClusterProfiles are marked with the value ClusterProfileMember instead of true.
+	MemberLabel = "licenses.appscode.com/fleet-member"
+	// ClusterUIDKey holds the UID of the member cluster
+	ClusterUIDKey = "clusterUID"
+	// FeaturesKey holds the comma separated features the member cluster wants licenses for
+	FeaturesKey = "features"
+)
+
+// LicenseSecretName returns the name of the secret licenses of a member are published in.
+func LicenseSecretName(cluster string) string {
+	return cluster + "-licenses"
+}
+
+// SharedSpoke reports the features wanted by the cluster in its member ConfigMap on the hub.
+// The hub credentials need to allow writing the member ConfigMap and reading the license secret.
+type SharedSpoke struct {
+	// HubKubeconfig is the path of the hub kubeconfig. It is read on every update,
+	// so that rotated credentials are picked up.
+	HubKubeconfig string
+	Namespace     string
+	ClusterName   string
+	ClusterID     string
+}
+
+var _ Spoke = &SharedSpoke{}
+
+func (s *SharedSpoke) UpdateClaim(ctx context.Context, mutate MutateFunc) error {
+	return updateMemberConfigMap(ctx, s.HubKubeconfig, s.Namespace, s.ClusterName, s.ClusterID, "true", mutate)
+}
+
+// newHubClient returns a client of the hub for the kubeconfig. It is replaced in tests.
+var newHubClient = func(kubeconfig string) (client.Client, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build hub rest config")
+	}
+	kc, err := client.New(cfg, client.Options{Scheme: clientgoscheme.Scheme})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create hub client")
+	}
+	return kc, nil
+}
+
+// updateMemberConfigMap updates the claim in the member ConfigMap of a cluster on the hub.
+// member is the value of the MemberLabel of the ConfigMap.
+func updateMemberConfigMap(ctx context.Context, hubKubeconfig, namespace, clusterName, clusterID, member string, mutate MutateFunc) error {
+	kc, err := newHubClient(hubKubeconfig)
+	if err != nil {
+		return err
+	}
+
+	return updateClaim(ctx, kc, claimCodec[*core.ConfigMap]{
+		newObject: func() *core.ConfigMap {
+			return &core.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      clusterName,
+					Namespace: namespace,
+				},
+			}
+		},
+		decode: func(cm *core.ConfigMap) *Claim {
+			return decodeClaim(cm, cm.Data[FeaturesKey])
+		},
+		encode: func(claim *Claim, cm *core.ConfigMap) error {
+			value, err := encodeClaim(claim, cm)
+			if cm.Labels == nil {
+				cm.Labels = map[string]string{}
+			}
+			cm.Labels[MemberLabel] = member
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+			cm.Data[ClusterUIDKey] = clusterID
+			cm.Data[FeaturesKey] = value
+			return err
+		},
+	}, mutate)
+}
+
+func (s *SharedSpoke) LicenseSecret() client.ObjectKey {
+	return client.ObjectKey{Name: LicenseSecretName(s.ClusterName), Namespace: s.Namespace}
+}
+
+func (s *SharedSpoke) FetchLicenses(ctx context.Context, hub client.Reader) (map[string][]byte, error) {
+	return fetchLicenses(ctx, hub, s.LicenseSecret())
+}
+
+// SharedHub serves the member ConfigMaps in the shared namespace.
+type SharedHub struct {
+	Client    client.Client
+	Namespace string
+}
+
+var _ Hub = &SharedHub{}
+
+func (h *SharedHub) MemberType() client.Object {
+	return &core.ConfigMap{}
+}
+
+func (h *SharedHub) IsMember(obj client.Object) bool {
+	return obj.GetNamespace() == h.Namespace && obj.GetLabels()[MemberLabel] == "true"
+}
+
+func (h *SharedHub) ListMembers(ctx context.Context) ([]Member, error) {
+	var list core.ConfigMapList
+	if err := h.Client.List(ctx, &list, client.InNamespace(h.Namespace), client.MatchingLabels{MemberLabel: "true"}); err != nil {
+		return nil, err
+	}
+	members := make([]Member, 0, len(list.Items))
+	for i := range list.Items {
+		members = append(members, configMapMember(&list.Items[i]))
+	}
+	return members, nil
+}
+
+func (h *SharedHub) GetMember(ctx context.Context, key client.ObjectKey) (*Member, error) {
+	var cm core.ConfigMap
+	if err := h.Client.Get(ctx, key, &cm); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !h.IsMember(&cm) {
+		return nil, nil
+	}
+	member := configMapMember(&cm)
+	return &member, nil
+}
+
+func (h *SharedHub) LicenseSecret(member *Member) client.ObjectKey {
+	return client.ObjectKey{Name: LicenseSecretName(member.Name), Namespace: h.Namespace}
+}
+
+func configMapMember(cm *core.ConfigMap) Member {
+	return Member{
+		Name:     cm.Name,
+		Key:      cm.Name,
+		UID:      cm.Data[ClusterUIDKey],
+		Features: info.ParseFeatures(cm.Data[FeaturesKey]),
+		Object:   cm,
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Conditions set on the license-proxyserver ManagedClusterAddOn of a cluster.
+const (
+	// ConditionFeaturesDenied is true if LicensePolicies deny some of the features claimed by the cluster.
+	ConditionFeaturesDenied = "LicenseFeaturesDenied"
+	// ConditionLicensesAcquired is true if every allowed feature claimed by the cluster has a license.
+	// The message lists the unlicensed features or the earliest license expiry.
+	ConditionLicensesAcquired = "LicensesAcquired"
+	// ConditionIssuerFailed is true if the last license request to the issuer failed.
+	ConditionIssuerFailed = "LicenseIssuerFailed"
+	// ConditionCSRApproved is false if the last CSR of the addon agent was not approved by the CSR approval policy.
+	ConditionCSRApproved = "AgentCSRApproved"
+)
+
+func licensesAcquiredCondition(unlicensed []string, earliestExpiry time.Time) metav1.Condition {
+	if len(unlicensed) > 0 {
+		return metav1.Condition{
+			Type:    ConditionLicensesAcquired,
+			Status:  metav1.ConditionFalse,
+			Reason:  "FeaturesUnlicensed",
+			Message: fmt.Sprintf("no license for features %s", strings.Join(unlicensed, ",")),
+		}
+	}
+	cond := metav1.Condition{
+		Type:    ConditionLicensesAcquired,
+		Status:  metav1.ConditionTrue,
+		Reason:  "LicensesAcquired",
+		Message: "all claimed features are licensed",
+	}
+	if !earliestExpiry.IsZero() {
+		cond.Message = fmt.Sprintf("all claimed features are licensed, earliest license expires at %s", earliestExpiry.UTC().Format(time.RFC3339))
+	}
+	return cond
+}
+
+// noIssuerRequestCondition resets the issuer condition, if no license had to be requested.
+// Otherwise, the failure of an earlier request would be reported indefinitely.
+func noIssuerRequestCondition() metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionIssuerFailed,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoIssuerRequestNeeded",
+		Message: "no license needs to be requested from the issuer",
+	}
+}
+
+// issuerCredentialsCondition reports that no license can be requested, as the issuer credentials
+// of the cluster are missing or invalid.
+func issuerCredentialsCondition(err error) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionIssuerFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "InvalidIssuerCredentials",
+		Message: err.Error(),
+	}
+}
+
+func issuerCondition(errList []error) metav1.Condition {
+	if len(errList) == 0 {
+		return metav1.Condition{
+			Type:    ConditionIssuerFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  "LicensesIssued",
+			Message: "license issuer accepted the last request",
+		}
+	}
+	reason := "IssuerRequestFailed"
+	for _, err := range errList {
+		if isInvalidCertificate(err) {
+			reason = "InvalidLicenseCertificate"
+			break
+		}
+	}
+	return metav1.Condition{
+		Type:    ConditionIssuerFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: utilerrors.NewAggregate(errList).Error(),
+	}
+}
+
+// isInvalidCertificate returns true if the issuer returned a license that fails verification.
+// The verifier wraps x509.CertificateInvalidError by value.
+func isInvalidCertificate(err error) bool {
+	var ce x509.CertificateInvalidError
+	return errors.As(err, &ce)
+}
+
+// setAddonConditions updates the conditions of the cluster's ManagedClusterAddOn.
+// The addon may not exist yet, as the ManagedCluster is reconciled independently.
+// Members of other fleet backends have no addon.
+func (r *LicenseAcquirer) setAddonConditions(ctx context.Context, clusterName string, conditions ...metav1.Condition) error {
+	if !r.ocm {
+		return nil
+	}
+	return setAddonConditions(ctx, r.Client, clusterName, conditions...)
+}
+
+func setAddonConditions(ctx context.Context, kc client.Client, clusterName string, conditions ...metav1.Condition) error {
+	var addon addonv1alpha1.ManagedClusterAddOn
+	err := kc.Get(ctx, client.ObjectKey{Name: common.AddonName, Namespace: clusterName}, &addon)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	orig := addon.DeepCopy()
+	var changed bool
+	for _, cond := range conditions {
+		cond.ObservedGeneration = addon.Generation
+		if meta.SetStatusCondition(&addon.Status.Conditions, cond) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return kc.Status().Patch(ctx, &addon, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{}))
+}
@@ -0,0 +1,58 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"testing"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const clusterUID = "8d4bd39a-a1a4-4b2b-9d6b-2f0e5b1c3e7a"
+
+func TestIssuerConditionInvalidCertificate(t *testing.T) {
+	opts := devissuer.DefaultLicenseOptions()
+	opts.Status = devissuer.StatusExpired
+	iss, err := devissuer.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := trust.NewBundle(iss.CACertPEM())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, err := iss.Issue(clusterUID, []string{"kubedb-ext-stash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = bundle.ParseLicense(clusterUID, data)
+	if err == nil {
+		t.Fatal("expected expired license to fail verification")
+	}
+
+	if errs := ignoreInvalidCertificate([]error{err}); len(errs) != 0 {
+		t.Errorf("ignoreInvalidCertificate() = %v, want no errors", errs)
+	}
+	cond := issuerCondition([]error{err})
+	if cond.Status != metav1.ConditionTrue || cond.Reason != "InvalidLicenseCertificate" {
+		t.Errorf("issuerCondition() = %+v", cond)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// clusterRegistry returns the license registry of a cluster. A new registry is warmed
+// from the cluster's cache directory and its license secret on the hub, so that licenses
+// acquired before a restart or by a previous leader are not requested from the issuer again.
+func (r *LicenseAcquirer) clusterRegistry(ctx context.Context, member *fleet.Member) (*storage.LicenseRegistry, error) {
+	reg, created, err := r.getLicenseRegistry(member.UID)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := r.warmClusterCache(ctx, reg, member); err != nil {
+			klog.ErrorS(err, "failed to warm license cache", "clusterName", member.Name, "clusterUID", member.UID)
+			if r.Recorder != nil {
+				r.Recorder.Eventf(member.Object, core.EventTypeWarning, "LicenseCacheInvalid", "failed to load cached licenses: %v", err)
+			}
+		}
+	}
+	return reg, nil
+}
+
+func (r *LicenseAcquirer) warmClusterCache(ctx context.Context, reg *storage.LicenseRegistry, member *fleet.Member) error {
+	cid := member.UID
+	var errList []error
+	invalid, err := storage.LoadDir(cid, filepath.Join(r.CacheDir, cid), r.CABundle, reg)
+	if err != nil {
+		errList = append(errList, err)
+	}
+	for _, file := range sets.List(sets.KeySet(invalid)) {
+		errList = append(errList, fmt.Errorf("failed to parse %s: %w", file, invalid[file]))
+	}
+
+	var sec core.Secret
+	err = r.Get(ctx, r.Fleet.LicenseSecret(member), &sec)
+	if apierrors.IsNotFound(err) {
+		return utilerrors.NewAggregate(errList)
+	} else if err != nil {
+		return utilerrors.NewAggregate(append(errList, err))
+	}
+	if prev := sec.Annotations[common.ClusterUIDAnnotation]; prev != "" && prev != cid {
+		// the licenses were issued to the previous UID of a rebuilt cluster and are pruned by the reconcile
+		return utilerrors.NewAggregate(errList)
+	}
+	for key, data := range sec.Data {
+		if key == common.LicenseIndexKey {
+			continue
+		}
+		l, anchor, err := r.CABundle.ParseLicense(cid, data)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("failed to parse %s in secret %s/%s: %w", key, sec.Namespace, sec.Name, err))
+			continue
+		}
+		if time.Until(l.NotAfter.Time) >= ttl {
+			reg.Add(&l, nil, anchor)
+		}
+	}
+	return utilerrors.NewAggregate(errList)
+}
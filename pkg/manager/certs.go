@@ -0,0 +1,227 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+
+	"gomodules.xyz/blobfs"
+	"gomodules.xyz/cert"
+	"gomodules.xyz/cert/certstore"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// caBundleFile holds the PEM encoded CAs trusted for the agent serving certificates: the current CA
+// and the CAs it recently replaced. Agents trust the bundle, so that serving certificates issued
+// before a CA rotation stay valid until the agents are updated.
+const caBundleFile = "ca-bundle.crt"
+
+// retiredCAsFile holds the retired CAs of the bundle, with the time they were retired
+// in the retiredAtHeader PEM header.
+const (
+	retiredCAsFile  = "ca-retired.crt"
+	retiredAtHeader = "Retired-At"
+)
+
+// AgentCertRotator issues the serving certificate of the proxy agents from a CA stored in the agent
+// config secret. The CA and the serving certificate are renewed when a third of their validity is
+// left, or if they were issued with a longer validity than configured.
+type AgentCertRotator struct {
+	FS           blobfs.Interface
+	CAValidity   time.Duration
+	CertValidity time.Duration
+	AltNames     cert.AltNames
+	// CheckInterval is the period of the renewal checks
+	CheckInterval time.Duration
+	// OnRotate is called after the CA bundle or the serving certificate changed
+	OnRotate func(ctx context.Context)
+
+	ready atomic.Bool
+}
+
+// Ready returns true once the CA and the serving certificate were issued.
+func (r *AgentCertRotator) Ready() bool {
+	return r.ready.Load()
+}
+
+// Start checks the certificates periodically until the context is done. The agents are
+// rendered with the certificates of the first check, so OnRotate is not called for it.
+func (r *AgentCertRotator) Start(ctx context.Context) error {
+	if _, err := r.rotate(time.Now()); err != nil {
+		return err
+	}
+	r.ready.Store(true)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.check(ctx); err != nil {
+			klog.ErrorS(err, "failed to renew agent serving certificates")
+		}
+	}, r.CheckInterval)
+	return nil
+}
+
+func (r *AgentCertRotator) check(ctx context.Context) error {
+	changed, err := r.rotate(time.Now())
+	if err != nil {
+		return err
+	}
+	if changed && r.OnRotate != nil {
+		r.OnRotate(ctx)
+	}
+	return nil
+}
+
+// rotate renews the CA and the serving certificate if needed and returns true if any of them changed.
+func (r *AgentCertRotator) rotate(now time.Time) (bool, error) {
+	// the cert store issues CAs valid for ten times its duration
+	cs := certstore.New(r.FS, "", r.CAValidity/10)
+
+	var changed bool
+	var retired *x509.Certificate
+	err := cs.LoadCA()
+	if errors.Is(err, os.ErrNotExist) {
+		if err := cs.NewCA(); err != nil {
+			return false, err
+		}
+		changed = true
+	} else if err != nil {
+		return false, err
+	} else if needsRenewal(cs.CACert(), r.CAValidity, now) {
+		klog.InfoS("renewing agent CA", "notAfter", cs.CACert().NotAfter)
+		retired = cs.CACert()
+		if err := cs.NewCA(); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	bundleChanged, err := r.updateCABundle(cs.CACert(), retired, now)
+	if err != nil {
+		return false, err
+	}
+
+	crt, _, err := cs.Read(common.ServerCertName)
+	if err != nil || crt.CheckSignatureFrom(cs.CACert()) != nil || needsRenewal(crt, r.CertValidity, now) {
+		if crt != nil {
+			klog.InfoS("renewing agent serving certificate", "notAfter", crt.NotAfter)
+		}
+		key, err := cert.NewPrivateKey()
+		if err != nil {
+			return false, err
+		}
+		crt, err = cert.NewSignedCert(cert.Config{
+			CommonName: r.AltNames.DNSNames[0],
+			AltNames:   r.AltNames,
+			Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			Duration:   r.CertValidity,
+		}, key, cs.CACert(), cs.CAKey())
+		if err != nil {
+			return false, fmt.Errorf("failed to issue agent serving certificate: %w", err)
+		}
+		if err := cs.Write(common.ServerCertName, crt, key); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed || bundleChanged, nil
+}
+
+// updateCABundle writes the current CA and the retired CAs to the bundle. Retired CAs are kept
+// with their retirement time as a PEM header in a separate file, as PEM blocks with headers are
+// ignored by certificate pools, and dropped one serving certificate validity after retirement,
+// once the agents were updated with a serving certificate issued by the current CA.
+func (r *AgentCertRotator) updateCABundle(current, retired *x509.Certificate, now time.Time) (bool, error) {
+	var blocks []*pem.Block
+	if retired != nil {
+		blocks = append(blocks, &pem.Block{
+			Type:    cert.CertificateBlockType,
+			Headers: map[string]string{retiredAtHeader: now.UTC().Format(time.RFC3339)},
+			Bytes:   retired.Raw,
+		})
+	}
+	// the files do not exist before the first check
+	prev, _ := r.FS.ReadFile(context.TODO(), retiredCAsFile)
+	for rest := prev; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		retiredAt, err := time.Parse(time.RFC3339, block.Headers[retiredAtHeader])
+		if err != nil || now.After(retiredAt.Add(r.CertValidity)) {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil || now.After(ca.NotAfter) || ca.Equal(current) || (retired != nil && ca.Equal(retired)) {
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+
+	var retiredCAs bytes.Buffer
+	caBundle := bytes.NewBuffer(cert.EncodeCertPEM(current))
+	for _, block := range blocks {
+		if err := pem.Encode(&retiredCAs, block); err != nil {
+			return false, err
+		}
+		caBundle.Write(cert.EncodeCertPEM(&x509.Certificate{Raw: block.Bytes}))
+	}
+
+	var changed bool
+	for file, data := range map[string][]byte{retiredCAsFile: retiredCAs.Bytes(), caBundleFile: caBundle.Bytes()} {
+		if cur, _ := r.FS.ReadFile(context.TODO(), file); bytes.Equal(cur, data) {
+			continue
+		}
+		if err := r.FS.WriteFile(context.TODO(), file, data); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// ServingCerts returns the PEM encoded CA bundle, serving certificate and key of the agents.
+func (r *AgentCertRotator) ServingCerts() ([]byte, []byte, []byte, error) {
+	caBundle, err := r.FS.ReadFile(context.TODO(), caBundleFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cs := certstore.New(r.FS, "", r.CAValidity/10)
+	crt, key, err := cs.ReadBytes(common.ServerCertName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return caBundle, crt, key, nil
+}
+
+// needsRenewal returns true if a third of the validity of the certificate is left, or if
+// more than the validity is left, as the certificate was issued with a longer validity.
+// NotBefore is not used, as signed certificates are backdated to the NotBefore of the CA.
+func needsRenewal(crt *x509.Certificate, validity time.Duration, now time.Time) bool {
+	left := crt.NotAfter.Sub(now)
+	return left < validity/3 || left > validity+time.Hour
+}
@@ -0,0 +1,114 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"gocloud.dev/blob"
+	"gomodules.xyz/cert"
+)
+
+// memFS is a blobfs.Interface keeping files in memory, like the agent config secret.
+type memFS map[string][]byte
+
+func (fs memFS) WriteFile(_ context.Context, filepath string, data []byte) error {
+	fs[filepath] = data
+	return nil
+}
+
+func (fs memFS) ReadFile(_ context.Context, filepath string) ([]byte, error) {
+	data, found := fs[filepath]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func (fs memFS) DeleteFile(_ context.Context, filepath string) error {
+	delete(fs, filepath)
+	return nil
+}
+
+func (fs memFS) Exists(_ context.Context, filepath string) (bool, error) {
+	_, found := fs[filepath]
+	return found, nil
+}
+
+func (fs memFS) SignedURL(_ context.Context, _ string, _ *blob.SignedURLOptions) (string, error) {
+	panic("unsupported")
+}
+
+func TestAgentCertRotation(t *testing.T) {
+	const day = 24 * time.Hour
+	r := &AgentCertRotator{
+		FS:           memFS{},
+		CAValidity:   300 * day,
+		CertValidity: 30 * day,
+		AltNames:     cert.AltNames{DNSNames: []string{"license-proxyserver.kubeops.svc"}},
+	}
+
+	verify := func(wantCAs int) {
+		t.Helper()
+		caBundle, crtPEM, _, err := r.ServingCerts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cas, err := cert.ParseCertsPEM(caBundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cas) != wantCAs {
+			t.Errorf("CA bundle has %d CAs, want %d", len(cas), wantCAs)
+		}
+		crts, err := cert.ParseCertsPEM(crtPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crts[0].CheckSignatureFrom(cas[0]); err != nil {
+			t.Errorf("serving certificate is not issued by the current CA: %v", err)
+		}
+	}
+	rotate := func(now time.Time, wantChanged bool) {
+		t.Helper()
+		if changed, err := r.rotate(now); err != nil || changed != wantChanged {
+			t.Fatalf("rotate() = %v, %v, want %v", changed, err, wantChanged)
+		}
+	}
+
+	now := time.Now()
+	rotate(now, true)
+	verify(1)
+	rotate(now, false)
+
+	// the serving certificate is renewed by the same CA
+	rotate(now.Add(25*day), true)
+	verify(1)
+
+	// a CA issued with a longer validity is renewed, the retired CA stays
+	// trusted until the agents have serving certificates of the new CA
+	r.CAValidity = 90 * day
+	rotate(now, true)
+	verify(2)
+	rotate(now, false)
+
+	rotate(now.Add(r.CertValidity+time.Hour), true)
+	verify(1)
+}
@@ -19,15 +19,19 @@ package manager
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"os"
+	"path"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
 	"go.bytebuilders.dev/license-proxyserver/pkg/common"
 
 	"github.com/pkg/errors"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
-	"gomodules.xyz/cert/certstore"
 	corev1 "k8s.io/api/core/v1"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -38,35 +42,40 @@ import (
 	kmapi "kmodules.xyz/client-go/api/v1"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
 	agentapi "open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1beta1 "open-cluster-management.io/api/addon/v1beta1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
 	workv1 "open-cluster-management.io/api/work/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
 
+// agentContainerName is the name of the proxy container in the agent deployment.
+const agentContainerName = "server"
+
 var scheme = runtime.NewScheme()
 
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = clusterv1.Install(scheme)
 	_ = clusterv1alpha1.Install(scheme)
+	_ = clusterv1beta2.Install(scheme)
+	_ = hubapi.AddToScheme(scheme)
 	_ = apiregistrationv1.AddToScheme(scheme)
 	_ = monitoringv1.AddToScheme(scheme)
 	_ = addonv1alpha1.Install(scheme)
 	_ = addonv1beta1.Install(scheme)
 }
 
-func GetConfigValues(kc client.Client, opts *ManagerOptions, cs *certstore.CertStore) addonfactory.GetValuesFunc {
+// GetConfigValues returns the values of the proxy addon of a cluster. defaults are the issuer
+// credentials used for clusters not selected by an issuer credentials secret.
+func GetConfigValues(kc client.Client, opts *ManagerOptions, defaults *IssuerCredentials, certs *AgentCertRotator, adcGetter utils.AddOnDeploymentConfigGetter) addonfactory.GetValuesFunc {
 	return func(cluster *clusterv1.ManagedCluster, addon *addonv1alpha1.ManagedClusterAddOn) (addonfactory.Values, error) {
-		caCrtBytes, _, err := cs.ReadBytes(common.CACertName)
-		if err != nil {
-			return nil, err
-		}
-
-		crtBytes, keyBytes, err := cs.ReadBytes(common.ServerCertName)
+		// the CA bundle trusts the current and the recently retired CAs
+		caCrtBytes, crtBytes, keyBytes, err := certs.ServingCerts()
 		if err != nil {
 			return nil, err
 		}
@@ -99,30 +108,30 @@ func GetConfigValues(kc client.Client, opts *ManagerOptions, cs *certstore.CertS
 				return nil, err
 			}
 		}
-		if opts.BaseURL != "" {
-			err = unstructured.SetNestedField(vals, opts.BaseURL, "platform", "baseURL")
+		issuer, err := resolveIssuerCredentials(context.Background(), kc, defaults, cluster)
+		if err != nil {
+			return nil, err
+		}
+		if issuer.BaseURL != "" {
+			err = unstructured.SetNestedField(vals, issuer.BaseURL, "platform", "baseURL")
 			if err != nil {
 				return nil, err
 			}
 		}
-		if opts.CAFile != "" {
-			caCert, err := os.ReadFile(opts.CAFile)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to read CA file %s", opts.CAFile)
-			}
-			err = unstructured.SetNestedField(vals, string(caCert), "platform", "caBundle")
+		if len(issuer.CaCert) > 0 {
+			err = unstructured.SetNestedField(vals, string(issuer.CaCert), "platform", "caBundle")
 			if err != nil {
 				return nil, err
 			}
 		}
-		if opts.InsecureSkipTLSVerify {
-			err = unstructured.SetNestedField(vals, opts.InsecureSkipTLSVerify, "platform", "insecureSkipTLSVerify")
+		if issuer.InsecureSkipTLSVerify {
+			err = unstructured.SetNestedField(vals, issuer.InsecureSkipTLSVerify, "platform", "insecureSkipTLSVerify")
 			if err != nil {
 				return nil, err
 			}
 		}
-		if opts.Token != "" {
-			err = unstructured.SetNestedField(vals, opts.Token, "platform", "token")
+		if issuer.Token != "" {
+			err = unstructured.SetNestedField(vals, issuer.Token, "platform", "token")
 			if err != nil {
 				return nil, err
 			}
@@ -159,6 +168,18 @@ func GetConfigValues(kc client.Client, opts *ManagerOptions, cs *certstore.CertS
 			}
 		}
 
+		// the AddOnDeploymentConfig referenced by the ManagedClusterAddOn, or by default by the
+		// ClusterManagementAddOn, is resolved by the addon manager into the addon status
+		adc, err := utils.GetDesiredAddOnDeploymentConfig(addon, adcGetter)
+		if err != nil {
+			return nil, err
+		}
+		if adc != nil {
+			if err := applyDeploymentConfig(vals, adc); err != nil {
+				return nil, errors.Wrapf(err, "failed to apply AddOnDeploymentConfig %s/%s", adc.Namespace, adc.Name)
+			}
+		}
+
 		var sec corev1.Secret
 		err = kc.Get(context.Background(), types.NamespacedName{Name: common.LicenseSecret, Namespace: cluster.Name}, &sec)
 		if err != nil && kerr.IsNotFound(err) {
@@ -170,6 +191,9 @@ func GetConfigValues(kc client.Client, opts *ManagerOptions, cs *certstore.CertS
 		if sec.Data != nil {
 			licenses := make(map[string]any)
 			for key, value := range sec.Data {
+				if key == common.LicenseIndexKey {
+					continue
+				}
 				licenses[key] = string(value)
 			}
 
@@ -183,6 +207,110 @@ func GetConfigValues(kc client.Client, opts *ManagerOptions, cs *certstore.CertS
 	}
 }
 
+// applyDeploymentConfig overrides the chart values with the node placement, resource requirements,
+// proxy settings, image registries and the logLevel customized variable of an AddOnDeploymentConfig.
+func applyDeploymentConfig(vals map[string]any, adc *addonv1alpha1.AddOnDeploymentConfig) error {
+	if np := adc.Spec.NodePlacement; np != nil {
+		if np.NodeSelector != nil {
+			if err := setNestedValue(vals, np.NodeSelector, "nodeSelector"); err != nil {
+				return err
+			}
+		}
+		if np.Tolerations != nil {
+			if err := setNestedValue(vals, np.Tolerations, "tolerations"); err != nil {
+				return err
+			}
+		}
+	}
+
+	containerID := fmt.Sprintf("deployments:%s:%s", common.AgentName, agentContainerName)
+	requirements, err := addonfactory.GetRegexResourceRequirements(adc.Spec.ResourceRequirements)
+	if err != nil {
+		return err
+	}
+	// the last matching requirement wins, as in the addon framework
+	for _, r := range requirements {
+		matched, err := regexp.MatchString(r.ContainerIDRegex, containerID)
+		if err != nil {
+			return err
+		}
+		if matched {
+			if err := setNestedValue(vals, r.ResourcesRaw, "image", "resources"); err != nil {
+				return err
+			}
+		}
+	}
+
+	proxy := adc.Spec.ProxyConfig
+	proxyEnv := map[string]string{
+		"HTTP_PROXY":  proxy.HTTPProxy,
+		"HTTPS_PROXY": proxy.HTTPSProxy,
+		"NO_PROXY":    proxy.NoProxy,
+	}
+	env, _, err := unstructured.NestedSlice(vals, "env")
+	if err != nil {
+		return err
+	}
+	env = slices.DeleteFunc(env, func(e any) bool {
+		m, ok := e.(map[string]any)
+		return ok && proxyEnv[fmt.Sprint(m["name"])] != ""
+	})
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		if proxyEnv[name] != "" {
+			env = append(env, map[string]any{"name": name, "value": proxyEnv[name]})
+		}
+	}
+	if err := unstructured.SetNestedSlice(vals, env, "env"); err != nil {
+		return err
+	}
+
+	for _, v := range adc.Spec.CustomizedVariables {
+		if v.Name != "logLevel" {
+			continue
+		}
+		level, err := strconv.ParseInt(v.Value, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid logLevel %q", v.Value)
+		}
+		if err := unstructured.SetNestedField(vals, level, "logLevel"); err != nil {
+			return err
+		}
+	}
+
+	if len(adc.Spec.Registries) > 0 {
+		registryFQDN, _, _ := unstructured.NestedString(vals, "registryFQDN")
+		registry, _, _ := unstructured.NestedString(vals, "image", "registry")
+		repository, _, _ := unstructured.NestedString(vals, "image", "repository")
+		image := strings.Join(slices.DeleteFunc([]string{registryFQDN, registry, repository}, func(s string) bool { return s == "" }), "/")
+		if mirrored := addonfactory.OverrideImage(adc.Spec.Registries, image); mirrored != image {
+			dir, name := path.Split(mirrored)
+			if err := unstructured.SetNestedField(vals, "", "registryFQDN"); err != nil {
+				return err
+			}
+			if err := unstructured.SetNestedField(vals, strings.TrimSuffix(dir, "/"), "image", "registry"); err != nil {
+				return err
+			}
+			if err := unstructured.SetNestedField(vals, name, "image", "repository"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setNestedValue sets a typed value as its JSON representation, so that it can be rendered by the chart.
+func setNestedValue(vals map[string]any, v any, fields ...string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+	return unstructured.SetNestedField(vals, out, fields...)
+}
+
 func agentHealthProber() *agentapi.HealthProber {
 	return &agentapi.HealthProber{
 		Type: agentapi.HealthProberTypeWork,
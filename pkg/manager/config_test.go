@@ -0,0 +1,83 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestApplyDeploymentConfig(t *testing.T) {
+	data, err := FS.ReadFile("agent-manifests/license-proxyserver/values.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vals map[string]any
+	if err := yaml.Unmarshal(data, &vals); err != nil {
+		t.Fatal(err)
+	}
+
+	adc := &addonv1alpha1.AddOnDeploymentConfig{
+		Spec: addonv1alpha1.AddOnDeploymentConfigSpec{
+			NodePlacement: &addonv1alpha1.NodePlacement{
+				NodeSelector: map[string]string{"node-role": "infra"},
+				Tolerations:  []corev1.Toleration{{Key: "infra", Operator: corev1.TolerationOpExists}},
+			},
+			ResourceRequirements: []addonv1alpha1.ContainerResourceRequirements{
+				{
+					ContainerID: "*:*:*",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+					},
+				},
+			},
+			ProxyConfig: addonv1alpha1.ProxyConfig{
+				HTTPSProxy: "https://proxy:3129",
+				NoProxy:    "10.0.0.0/8",
+			},
+			CustomizedVariables: []addonv1alpha1.CustomizedVariable{{Name: "logLevel", Value: "5"}},
+			Registries:          []addonv1alpha1.ImageMirror{{Source: "ghcr.io/appscode", Mirror: "registry.local/mirror"}},
+		},
+	}
+	if err := applyDeploymentConfig(vals, adc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"nodeSelector":     map[string]any{"node-role": "infra"},
+		"tolerations":      []any{map[string]any{"key": "infra", "operator": "Exists"}},
+		"image.resources":  map[string]any{"limits": map[string]any{"memory": "256Mi"}},
+		"env":              []any{map[string]any{"name": "HTTPS_PROXY", "value": "https://proxy:3129"}, map[string]any{"name": "NO_PROXY", "value": "10.0.0.0/8"}},
+		"logLevel":         int64(5),
+		"registryFQDN":     "",
+		"image.registry":   "registry.local/mirror",
+		"image.repository": "license-proxyserver",
+	}
+	for key, v := range want {
+		got, _, _ := unstructured.NestedFieldNoCopy(vals, strings.Split(key, ".")...)
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("%s = %#v, want %#v", key, got, v)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CSRApprover approves the client certificate CSRs of addon agents of the clusters selected by
+// the cluster selector and the ManagedClusterSets, if the CSR requests the subject of the agent.
+// Empty selectors select all clusters.
+type CSRApprover struct {
+	Client          client.Client
+	Recorder        record.EventRecorder
+	AgentName       string
+	ClusterSelector *metav1.LabelSelector
+	ClusterSets     []string
+}
+
+// Approve is an agent.CSRApproveFunc. Denied CSRs are left pending; the reason is recorded
+// as an event on the ManagedCluster and as a condition of the ManagedClusterAddOn.
+func (a *CSRApprover) Approve(cluster *clusterv1.ManagedCluster, addon *addonv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) bool {
+	ctx := context.TODO()
+
+	err := a.check(ctx, cluster, addon, csr)
+	cond := metav1.Condition{
+		Type:    ConditionCSRApproved,
+		Status:  metav1.ConditionTrue,
+		Reason:  "CSRApproved",
+		Message: fmt.Sprintf("CSR %s was approved", csr.Name),
+	}
+	if err != nil {
+		klog.InfoS("CSR not approved", "clusterName", cluster.Name, "csr", csr.Name, "reason", err)
+		if a.Recorder != nil {
+			a.Recorder.Eventf(cluster, core.EventTypeWarning, "CSRDenied", "CSR %s of addon %s was not approved: %v", csr.Name, addon.Name, err)
+		}
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "CSRDenied"
+		cond.Message = fmt.Sprintf("CSR %s was not approved: %v", csr.Name, err)
+	}
+	if err := setAddonConditions(ctx, a.Client, cluster.Name, cond); err != nil {
+		klog.ErrorS(err, "failed to update addon status", "clusterName", cluster.Name)
+	}
+	return err == nil
+}
+
+func (a *CSRApprover) check(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonv1alpha1.ManagedClusterAddOn, csr *certificatesv1.CertificateSigningRequest) error {
+	selected, err := selectsCluster(ctx, a.Client, a.ClusterSelector, a.ClusterSets, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate CSR approval policy: %w", err)
+	}
+	if !selected {
+		return errors.New("cluster is not selected by the CSR approval policy")
+	}
+	return checkCSRSubject(cluster.Name, addon.Name, a.AgentName, csr)
+}
+
+// checkCSRSubject verifies that the CSR was requested by the cluster for the default user and groups
+// of the addon agent, as configured by agent.KubeClientSignerConfigurations.
+func checkCSRSubject(clusterName, addonName, agentName string, csr *certificatesv1.CertificateSigningRequest) error {
+	if csr.Spec.SignerName != certificatesv1.KubeAPIServerClientSignerName {
+		return fmt.Errorf("unexpected signer %s", csr.Spec.SignerName)
+	}
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return errors.New("request is not a PEM encoded CERTIFICATE REQUEST")
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse request: %w", err)
+	}
+
+	if user := agent.DefaultUser(clusterName, addonName, agentName); req.Subject.CommonName != user {
+		return fmt.Errorf("common name %q is not %q", req.Subject.CommonName, user)
+	}
+	if groups := sets.New(agent.DefaultGroups(clusterName, addonName)...); !groups.Equal(sets.New(req.Subject.Organization...)) {
+		return fmt.Errorf("organizations %v are not %v", req.Subject.Organization, sets.List(groups))
+	}
+	// CSRs are created by the registration agent of the cluster
+	if !strings.HasPrefix(csr.Spec.Username, "system:open-cluster-management:"+clusterName+":") {
+		return fmt.Errorf("requester %s does not belong to cluster %s", csr.Spec.Username, clusterName)
+	}
+	return nil
+}
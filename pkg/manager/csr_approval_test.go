@@ -0,0 +1,77 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+)
+
+func newAgentCSR(t *testing.T, cn string, orgs []string, username string) *certificatesv1.CertificateSigningRequest {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: cn, Organization: orgs},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificatesv1.CertificateSigningRequest{
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Username:   username,
+		},
+	}
+}
+
+func TestCheckCSRSubject(t *testing.T) {
+	user := agent.DefaultUser("c1", common.AddonName, common.AgentName)
+	groups := agent.DefaultGroups("c1", common.AddonName)
+	requester := "system:open-cluster-management:c1:agent"
+
+	tests := []struct {
+		name string
+		csr  *certificatesv1.CertificateSigningRequest
+		ok   bool
+	}{
+		{"valid", newAgentCSR(t, user, groups, requester), true},
+		{"other cluster user", newAgentCSR(t, agent.DefaultUser("c2", common.AddonName, common.AgentName), groups, requester), false},
+		{"extra group", newAgentCSR(t, user, append(groups, "system:masters"), requester), false},
+		{"other requester", newAgentCSR(t, user, groups, "system:open-cluster-management:c10:agent"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCSRSubject("c1", common.AddonName, common.AgentName, tt.csr)
+			if (err == nil) != tt.ok {
+				t.Errorf("checkCSRSubject() = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
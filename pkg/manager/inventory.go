@@ -0,0 +1,208 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var expiryWindows = []struct {
+	window hubapi.ExpiryWindow
+	d      time.Duration
+}{
+	{hubapi.ExpiryWindow7d, 7 * 24 * time.Hour},
+	{hubapi.ExpiryWindow30d, 30 * 24 * time.Hour},
+	{hubapi.ExpiryWindow90d, 90 * 24 * time.Hour},
+}
+
+// expiryWindow returns the smallest window containing the expiry time.
+func expiryWindow(expiry time.Time) (hubapi.ExpiryWindow, bool) {
+	left := time.Until(expiry)
+	if left <= 0 {
+		return hubapi.ExpiryWindowExpired, true
+	}
+	for _, w := range expiryWindows {
+		if left <= w.d {
+			return w.window, true
+		}
+	}
+	return "", false
+}
+
+// nextExpiryWindowChange returns the time until the expiry window of the expiry time changes.
+func nextExpiryWindowChange(expiry time.Time) time.Duration {
+	next := time.Until(expiry)
+	for _, w := range expiryWindows {
+		if d := time.Until(expiry.Add(-w.d)); d > 0 && d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+func licenseSummary(rec *storage.Record) hubapi.LicenseSummary {
+	return hubapi.LicenseSummary{
+		ID:          rec.License.ID,
+		ProductLine: rec.License.ProductLine,
+		TierName:    rec.License.TierName,
+		PlanName:    rec.License.PlanName,
+		Features:    slices.Clone(rec.License.Features),
+		NotAfter:    rec.License.NotAfter,
+		Contract:    rec.Contract,
+	}
+}
+
+// addUsage adds the usage last reported by the agent of the cluster to the inventory status.
+// Reports for a previous cluster UID are ignored. Usage is reported by OCM addon agents only.
+func (r *LicenseAcquirer) addUsage(ctx context.Context, clusterName string, status *hubapi.LicenseInventoryStatus) error {
+	if !r.ocm {
+		return nil
+	}
+	var sec core.Secret
+	err := r.Get(ctx, client.ObjectKey{Name: common.LicenseUsageSecret, Namespace: clusterName}, &sec)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	data, found := sec.Data[common.LicenseUsageKey]
+	if !found {
+		return nil
+	}
+	var report hubapi.UsageReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed to parse %s in secret %s/%s: %w", common.LicenseUsageKey, sec.Namespace, sec.Name, err)
+	}
+	if report.ClusterUID != status.ClusterUID {
+		return nil
+	}
+
+	status.Usage = report.Features
+	status.UsageReportTime = &report.ReportTime
+	lastRequest := map[string]metav1.Time{}
+	for _, fu := range report.Features {
+		lastRequest[fu.Feature] = fu.LastRequestTime
+	}
+	for i := range status.Licenses {
+		l := &status.Licenses[i]
+		for _, feature := range l.Features {
+			if t, found := lastRequest[feature]; found && (l.LastRequestTime == nil || l.LastRequestTime.Before(&t)) {
+				l.LastRequestTime = &t
+			}
+		}
+	}
+	return nil
+}
+
+// updateInventory writes the LicenseInventory of the cluster. Product and expiry window labels
+// allow selecting inventories with label selectors. If issued is false, no license was requested
+// from the issuer and the acquisition errors of the previous request are kept.
+func (r *LicenseAcquirer) updateInventory(ctx context.Context, member *fleet.Member, status hubapi.LicenseInventoryStatus, issued bool) error {
+	if !r.EnableInventory {
+		return nil
+	}
+
+	inv := hubapi.LicenseInventory{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: member.Key,
+		},
+	}
+	err := r.Get(ctx, client.ObjectKeyFromObject(&inv), &inv)
+	exists := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if !issued {
+		status.AcquisitionErrors = inv.Status.AcquisitionErrors
+	}
+	if err := r.addUsage(ctx, member.Name, &status); err != nil {
+		klog.ErrorS(err, "failed to read license usage report", "clusterName", member.Name)
+	}
+
+	labels := map[string]string{}
+	for key, value := range inv.Labels {
+		if key != hubapi.ExpiresWithinLabel && !strings.HasPrefix(key, hubapi.ProductLabelPrefix) {
+			labels[key] = value
+		}
+	}
+	for _, l := range status.Licenses {
+		if l.ProductLine != "" {
+			labels[hubapi.ProductLabelPrefix+l.ProductLine] = "true"
+		}
+	}
+	if status.EarliestExpiry != nil {
+		if w, found := expiryWindow(status.EarliestExpiry.Time); found {
+			labels[hubapi.ExpiresWithinLabel] = string(w)
+		}
+	}
+
+	if !exists {
+		inv.Labels = labels
+		// cluster scoped inventories can only be owned by cluster scoped members,
+		// others are deleted on cleanup
+		if member.Object.GetNamespace() == "" {
+			if err := controllerutil.SetOwnerReference(member.Object, &inv, r.Scheme()); err != nil {
+				return err
+			}
+		}
+		if err := r.Create(ctx, &inv); err != nil {
+			return err
+		}
+	} else if !equality.Semantic.DeepEqual(inv.Labels, labels) {
+		inv.Labels = labels
+		if err := r.Update(ctx, &inv); err != nil {
+			return err
+		}
+	}
+
+	if equality.Semantic.DeepEqual(inv.Status, status) {
+		return nil
+	}
+	inv.Status = status
+	return r.Status().Update(ctx, &inv)
+}
+
+// deleteInventory deletes the LicenseInventory of a removed member.
+func (r *LicenseAcquirer) deleteInventory(ctx context.Context, key string) error {
+	if !r.EnableInventory {
+		return nil
+	}
+	inv := hubapi.LicenseInventory{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: key,
+		},
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, &inv))
+}
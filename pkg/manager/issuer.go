@@ -0,0 +1,123 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IssuerCredentials are used to acquire licenses for a cluster from the license issuer.
+type IssuerCredentials struct {
+	BaseURL               string
+	Token                 string
+	CaCert                []byte
+	InsecureSkipTLSVerify bool
+
+	// source identifies the version of the secret the credentials were read from.
+	// It is empty for the credentials set by flags.
+	source string
+}
+
+// resolveIssuerCredentials returns the issuer credentials used for a cluster. The secret named by
+// the issuer credentials annotation of the cluster is used, if set. Otherwise, the first issuer
+// credentials secret, by name, that selects the cluster by label or ManagedClusterSet is used.
+// The defaults are used if no secret selects the cluster.
+func resolveIssuerCredentials(ctx context.Context, kc client.Reader, defaults *IssuerCredentials, cluster client.Object) (*IssuerCredentials, error) {
+	if name := cluster.GetAnnotations()[common.IssuerCredentialsLabel]; name != "" {
+		var sec core.Secret
+		err := kc.Get(ctx, client.ObjectKey{Name: name, Namespace: common.Namespace()}, &sec)
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("issuer credentials secret %s/%s not found", common.Namespace(), name)
+		} else if err != nil {
+			return nil, err
+		}
+		if sec.Labels[common.IssuerCredentialsLabel] != "true" {
+			return nil, fmt.Errorf("secret %s/%s is missing the label %s=true", sec.Namespace, sec.Name, common.IssuerCredentialsLabel)
+		}
+		return issuerCredentialsFromSecret(&sec)
+	}
+
+	var list core.SecretList
+	if err := kc.List(ctx, &list, client.InNamespace(common.Namespace()), client.MatchingLabels{common.IssuerCredentialsLabel: "true"}); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(list.Items, func(a, b core.Secret) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for i := range list.Items {
+		sec := &list.Items[i]
+		selected, err := secretSelectsCluster(ctx, kc, sec, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate issuer credentials secret %s: %w", sec.Name, err)
+		}
+		if selected {
+			return issuerCredentialsFromSecret(sec)
+		}
+	}
+	return defaults, nil
+}
+
+// secretSelectsCluster returns true if the selector annotations of an issuer credentials secret
+// select the cluster. A secret without selector annotations is only used if named by a cluster.
+func secretSelectsCluster(ctx context.Context, kc client.Reader, sec *core.Secret, cluster client.Object) (bool, error) {
+	var selector *metav1.LabelSelector
+	if v := sec.Annotations[common.IssuerClusterSelectorAnnotation]; v != "" {
+		var err error
+		selector, err = metav1.ParseToLabelSelector(v)
+		if err != nil {
+			return false, err
+		}
+	}
+	var clusterSets []string
+	for _, name := range strings.Split(sec.Annotations[common.IssuerClusterSetsAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			clusterSets = append(clusterSets, name)
+		}
+	}
+	if selector == nil && len(clusterSets) == 0 {
+		return false, nil
+	}
+	return selectsCluster(ctx, kc, selector, clusterSets, cluster)
+}
+
+func issuerCredentialsFromSecret(sec *core.Secret) (*IssuerCredentials, error) {
+	creds := &IssuerCredentials{
+		BaseURL: string(sec.Data[common.IssuerBaseURLKey]),
+		Token:   string(sec.Data[common.IssuerTokenKey]),
+		CaCert:  sec.Data[common.IssuerCAKey],
+		source:  fmt.Sprintf("%s/%s@%s", sec.Namespace, sec.Name, sec.ResourceVersion),
+	}
+	if v, found := sec.Data[common.IssuerInsecureSkipTLSVerifyKey]; found {
+		insecure, err := strconv.ParseBool(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in issuer credentials secret %s/%s: %w", common.IssuerInsecureSkipTLSVerifyKey, sec.Namespace, sec.Name, err)
+		}
+		creds.InsecureSkipTLSVerify = insecure
+	}
+	return creds, nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newIssuerSecret(name, selector string) *core.Secret {
+	sec := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "hub",
+			Labels:    map[string]string{common.IssuerCredentialsLabel: "true"},
+		},
+		Data: map[string][]byte{
+			common.IssuerBaseURLKey: []byte("https://" + name),
+			common.IssuerTokenKey:   []byte(name),
+		},
+	}
+	if selector != "" {
+		sec.Annotations = map[string]string{common.IssuerClusterSelectorAnnotation: selector}
+	}
+	return sec
+}
+
+func TestResolveIssuerCredentials(t *testing.T) {
+	t.Setenv("NAMESPACE", "hub")
+
+	kc := fake.NewClientBuilder().WithObjects(
+		newIssuerSecret("a-unselected", ""),
+		newIssuerSecret("b-customer", "customer=acme"),
+		newIssuerSecret("c-customer", "customer=acme"),
+		newIssuerSecret("d-named", ""),
+		&core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "hub"}},
+	).Build()
+	defaults := &IssuerCredentials{BaseURL: "https://defaults", Token: "defaults"}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		wantToken   string
+		wantErr     bool
+	}{
+		{name: "no secret selects the cluster", labels: map[string]string{"customer": "other"}, wantToken: "defaults"},
+		{name: "first selecting secret by name", labels: map[string]string{"customer": "acme"}, wantToken: "b-customer"},
+		{
+			name:        "annotation wins over selectors",
+			labels:      map[string]string{"customer": "acme"},
+			annotations: map[string]string{common.IssuerCredentialsLabel: "d-named"},
+			wantToken:   "d-named",
+		},
+		{
+			name:        "named secret without the label",
+			annotations: map[string]string{common.IssuerCredentialsLabel: "unlabeled"},
+			wantErr:     true,
+		},
+		{
+			name:        "named secret not found",
+			annotations: map[string]string{common.IssuerCredentialsLabel: "missing"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "c1", Labels: tt.labels, Annotations: tt.annotations},
+			}
+			creds, err := resolveIssuerCredentials(context.TODO(), kc, defaults, cluster)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("resolveIssuerCredentials() = %v, want an error", creds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds.Token != tt.wantToken {
+				t.Errorf("token = %s, want %s", creds.Token, tt.wantToken)
+			}
+			if tt.wantToken == "defaults" {
+				if creds != defaults {
+					t.Error("defaults are not used as is")
+				}
+			} else if creds.source == "" {
+				t.Error("source of the secret credentials is not set")
+			}
+		})
+	}
+}
+
+func TestReconcileReportsInvalidIssuerCredentials(t *testing.T) {
+	t.Setenv("NAMESPACE", "hub")
+
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "c1",
+			Annotations: map[string]string{common.IssuerCredentialsLabel: "missing"},
+		},
+		Status: clusterv1.ManagedClusterStatus{
+			ClusterClaims: []clusterv1.ManagedClusterClaim{
+				{Name: common.ClusterClaimClusterID, Value: clusterUID},
+				{Name: common.ClusterClaimLicense, Value: "kubedb"},
+			},
+		},
+	}
+	addon := &addonv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: common.AddonName, Namespace: "c1"},
+	}
+	kc := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster, addon).
+		WithStatusSubresource(addon).
+		Build()
+
+	r := &LicenseAcquirer{
+		Client:       kc,
+		Fleet:        &fleet.OCMHub{Client: kc},
+		Issuer:       &IssuerCredentials{BaseURL: "https://defaults"},
+		CacheDir:     t.TempDir(),
+		LicenseCache: map[string]*storage.LicenseRegistry{},
+		ocm:          true,
+	}
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKey{Name: "c1"}}); err == nil {
+		t.Fatal("Reconcile() succeeded with a missing issuer credentials secret")
+	}
+
+	if err := kc.Get(context.TODO(), client.ObjectKeyFromObject(addon), addon); err != nil {
+		t.Fatal(err)
+	}
+	cond := meta.FindStatusCondition(addon.Status.Conditions, ConditionIssuerFailed)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "InvalidIssuerCredentials" {
+		t.Errorf("issuer condition = %+v", cond)
+	}
+}
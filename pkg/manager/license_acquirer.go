@@ -17,32 +17,46 @@ limitations under the License.
 package manager
 
 import (
+	"bytes"
 	"context"
-	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
 	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
 	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
-	verifier "go.bytebuilders.dev/license-verifier"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
 	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
 	pc "go.bytebuilders.dev/license-verifier/client"
-	"go.bytebuilders.dev/license-verifier/info"
 
 	v "gomodules.xyz/x/version"
 	core "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
-	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -50,170 +64,546 @@ const ttl = storage.LicenseAcquisitionBuffer + storage.MinRemainingLife
 
 type LicenseAcquirer struct {
 	client.Client
-	BaseURL               string
-	Token                 string
-	CaCert                []byte
-	InsecureSkipTLSVerify bool
-	CacheDir              string
+	Fleet fleet.Hub
+	// Issuer holds the issuer credentials used for clusters not selected by an issuer credentials secret
+	Issuer   *IssuerCredentials
+	CacheDir string
+	CABundle *trust.Bundle
+	Recorder record.EventRecorder
+	// EnforcePolicies is set if the LicensePolicy CRD is installed on the hub
+	EnforcePolicies bool
+	// EnableInventory is set if the LicenseInventory CRD is installed on the hub
+	EnableInventory bool
+	// ocm is set if members are OCM ManagedClusters, which have ManagedClusterSets,
+	// ManagedClusterAddOns and report usage through the addon agent
+	ocm bool
 
 	mu           sync.Mutex
 	LicenseCache map[string]*storage.LicenseRegistry
+	clients      map[string]issuerClient // cluster uid -> issuer client
+}
+
+type issuerClient struct {
+	*pc.Client
+	// source of the credentials the client was created with
+	source string
 }
 
 var _ reconcile.Reconciler = &LicenseAcquirer{}
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *LicenseAcquirer) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&clusterv1.ManagedCluster{}).
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(r.Fleet.MemberType(), builder.WithPredicates(predicate.NewPredicateFuncs(r.Fleet.IsMember)))
+
+	_, r.ocm = r.Fleet.(*fleet.OCMHub)
+	if _, ok := r.Fleet.(*fleet.ClusterProfileHub); ok {
+		// the features of a ClusterProfile are claimed in a ConfigMap next to it
+		b = b.Watches(&core.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+			key, found := fleet.ClaimMemberKey(obj)
+			if !found {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: key}}
+		}))
+	}
+
+	// issuer credentials secrets may select any cluster, so a change is retried for all of them
+	b = b.Watches(&core.Secret{}, handler.EnqueueRequestsFromMapFunc(r.enqueueAllClusters),
+		builder.WithPredicates(predicate.NewPredicateFuncs(isIssuerCredentialsSecret)))
+
+	var err error
+	r.EnforcePolicies, err = crdInstalled(mgr, hubapi.SchemeGroupVersion.WithKind(hubapi.ResourceKindLicensePolicy))
+	if err != nil {
+		return err
+	}
+	if r.EnforcePolicies {
+		b = b.Watches(&hubapi.LicensePolicy{}, handler.EnqueueRequestsFromMapFunc(r.enqueueAllClusters))
+		if r.ocm {
+			b = b.Watches(&clusterv1beta2.ManagedClusterSet{}, handler.EnqueueRequestsFromMapFunc(r.enqueueAllClusters))
+		}
+	} else {
+		klog.InfoS("LicensePolicy CRD not found, license policies are not enforced")
+	}
+
+	r.EnableInventory, err = crdInstalled(mgr, hubapi.SchemeGroupVersion.WithKind(hubapi.ResourceKindLicenseInventory))
+	if err != nil {
+		return err
+	}
+	if r.EnableInventory {
+		b = b.Owns(&hubapi.LicenseInventory{})
+		if r.ocm {
+			b = b.Watches(&core.Secret{}, handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+				if obj.GetName() != common.LicenseUsageSecret {
+					return nil
+				}
+				// usage secrets live in the cluster namespace
+				return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: obj.GetNamespace()}}}
+			}))
+		}
+	} else {
+		klog.InfoS("LicenseInventory CRD not found, license inventory is disabled")
+	}
+	return b.Complete(r)
 }
 
-func (r *LicenseAcquirer) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
-	managedCluster := &clusterv1.ManagedCluster{}
-	err := r.Get(ctx, request.NamespacedName, managedCluster)
+func isIssuerCredentialsSecret(obj client.Object) bool {
+	return obj.GetNamespace() == common.Namespace() && obj.GetLabels()[common.IssuerCredentialsLabel] == "true"
+}
+
+func crdInstalled(mgr ctrl.Manager, gvk schema.GroupVersionKind) (bool, error) {
+	_, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *LicenseAcquirer) enqueueAllClusters(ctx context.Context, _ client.Object) []reconcile.Request {
+	members, err := r.Fleet.ListMembers(ctx)
 	if err != nil {
+		klog.ErrorS(err, "failed to list member clusters")
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(members))
+	for _, member := range members {
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(member.Object)})
+	}
+	return reqs
+}
+
+func (r *LicenseAcquirer) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	member, err := r.Fleet.GetMember(ctx, request.NamespacedName)
+	if err != nil || member == nil {
 		return reconcile.Result{}, err
 	}
 
-	var cid string
-	var features []string
-	for _, claim := range managedCluster.Status.ClusterClaims {
-		switch claim.Name {
-		case common.ClusterClaimClusterID:
-			cid = claim.Value
-		case common.ClusterClaimLicense:
-			features = strings.Split(claim.Value, ",")
-		}
+	if member.Object.GetDeletionTimestamp() != nil {
+		return reconcile.Result{}, r.cleanup(ctx, member)
 	}
-	if cid != "" && len(features) > 0 {
-		return r.reconcile(managedCluster.Name, cid, features)
+	if member.UID != "" {
+		return r.reconcile(ctx, member)
 	}
 
 	return reconcile.Result{}, nil
 }
 
-func (r *LicenseAcquirer) getLicenseRegistry(cid string) (*storage.LicenseRegistry, error) {
+func (r *LicenseAcquirer) getLicenseRegistry(cid string) (*storage.LicenseRegistry, bool, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	reg, found := r.LicenseCache[cid]
 	if found {
-		return reg, nil
+		return reg, false, nil
 	}
 
 	dir := filepath.Join(r.CacheDir, cid)
 	err := os.MkdirAll(dir, 0o755)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	reg = storage.NewLicenseRegistry(dir, ttl, nil)
 	r.LicenseCache[cid] = reg
-	return reg, nil
+	return reg, true, nil
 }
 
-func (r *LicenseAcquirer) reconcile(clusterName, cid string, features []string) (reconcile.Result, error) {
+func (r *LicenseAcquirer) reconcile(ctx context.Context, member *fleet.Member) (reconcile.Result, error) {
+	cluster, clusterName, cid, features := member.Object, member.Name, member.UID, member.Features
 	klog.InfoS("refreshing license", "clusterName", clusterName, "clusterUID", cid)
 
+	key := r.Fleet.LicenseSecret(member)
 	sec := core.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      common.LicenseSecret,
-			Namespace: clusterName,
+			Name:      key.Name,
+			Namespace: key.Namespace,
 		},
 	}
 	var secretExists bool
-	err := r.Get(context.TODO(), client.ObjectKey{Name: sec.Name, Namespace: sec.Namespace}, &sec)
+	err := r.Get(ctx, client.ObjectKey{Name: sec.Name, Namespace: sec.Namespace}, &sec)
 	if err == nil {
 		secretExists = true
-		if sec.Data == nil {
-			sec.Data = map[string][]byte{}
-		}
-	} else if apierrors.IsNotFound(err) {
-		sec.Data = map[string][]byte{}
-	} else {
+	} else if !apierrors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	if !secretExists && len(features) == 0 {
+		return reconcile.Result{}, nil
+	}
+	// the member has license state on the hub from here on, which is dropped on its removal
+	if err := r.addFinalizer(ctx, cluster); err != nil {
 		return reconcile.Result{}, err
 	}
+	if prev := sec.Annotations[common.ClusterUIDAnnotation]; prev != "" && prev != cid {
+		// cluster was rebuilt, licenses issued for the old UID are useless now
+		klog.InfoS("cluster UID changed", "clusterName", clusterName, "oldClusterUID", prev, "clusterUID", cid)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(cluster, core.EventTypeNormal, "ClusterUIDChanged", "cluster UID changed from %s to %s, acquiring new licenses", prev, cid)
+		}
+		if err := r.forgetCluster(prev); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
 
 	var errList []error
 	var earliestExpired time.Time
 
-	reg, err := r.getLicenseRegistry(cid)
+	reg, err := r.clusterRegistry(ctx, member)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-	for _, feature := range features {
-		l, found := reg.LicenseForFeature(feature)
-		if !found {
-			var c *v1alpha1.Contract
-			l, c, err = r.getNewLicense(cid, []string{feature})
-			if err == nil {
-
-				klog.InfoS("acquired new license",
-					"clusterName", clusterName,
-					"clusterUID", cid,
-					"licenseID", l.ID,
-					"product", l.ProductLine,
-					"plan", l.PlanName,
-					"expiry", l.NotAfter.UTC().Format(time.RFC822),
-				)
-				reg.Add(l, c)
-			} else {
-				klog.ErrorS(err, "failed to get new license", "feature", feature)
-				var ce *x509.CertificateInvalidError
-				if !errors.As(err, &ce) {
-					errList = append(errList, err)
-				}
+	policies, err := r.clusterPolicies(ctx, cluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	denied := deniedFeatures(policies, features)
+	allowed := slices.DeleteFunc(slices.Clone(features), func(feature string) bool {
+		_, found := denied[feature]
+		return found
+	})
+
+	var conditions []metav1.Condition
+	var issued bool
+	var issuerErrs []error
+	if missing := missingFeatures(reg, allowed); missing.Len() > 0 {
+		creds, err := resolveIssuerCredentials(ctx, r.Client, r.Issuer, cluster)
+		if err != nil {
+			if r.Recorder != nil {
+				r.Recorder.Eventf(cluster, core.EventTypeWarning, "IssuerCredentialsInvalid", "failed to resolve issuer credentials: %v", err)
+			}
+			return reconcile.Result{}, utilerrors.NewAggregate([]error{err, r.setAddonConditions(ctx, clusterName, issuerCredentialsCondition(err))})
+		}
+		issued = true
+		issuerErrs = r.acquireLicenses(member.Key, cid, creds, reg, missing)
+		conditions = append(conditions, issuerCondition(issuerErrs))
+		// retrying does not help if the issuer returns licenses that fail verification
+		// or rejects the requested features, these errors are reported in the addon status only
+		errList = append(errList, slices.DeleteFunc(ignoreInvalidCertificate(issuerErrs), func(err error) bool {
+			return !retriable(err)
+		})...)
+	} else {
+		conditions = append(conditions, noIssuerRequestCondition())
+	}
+
+	// rebuild the secret, so licenses for features no longer claimed
+	// or licenses that expired or got canceled are not synced any more.
+	// Entries are keyed by license ID, so a renewed license is rolled out
+	// next to the one it replaces. Licenses of tiers denied by a
+	// LicensePolicy stay on the hub only.
+	data := map[string][]byte{}
+	var licenses []hubapi.LicenseSummary
+	claimed := sets.New[string](allowed...)
+	for _, rec := range reg.List() {
+		if rec.License.Status == v1alpha1.LicenseActive && claimed.HasAny(rec.License.Features...) {
+			if _, found := licenseDeniedBy(policies, *rec.License); !found {
+				data[rec.License.ID] = rec.License.Data
+				licenses = append(licenses, licenseSummary(rec))
 			}
 		}
-		if l != nil && l.Status == v1alpha1.LicenseActive {
-			sec.Data[l.PlanName] = l.Data
+	}
+	index := map[string]string{}
+	for _, feature := range allowed {
+		l, found := reg.LicenseForFeature(feature)
+		if found && l.Status == v1alpha1.LicenseActive {
+			if policy, found := licenseDeniedBy(policies, *l); found {
+				denied[feature] = policy
+				continue
+			}
+			index[feature] = l.ID
 			if earliestExpired.IsZero() || earliestExpired.After(l.NotAfter.Time) {
 				earliestExpired = l.NotAfter.Time
 			}
 		}
 	}
-
-	if secretExists {
-		errList = append(errList, r.Update(context.TODO(), &sec))
+	var unlicensed []string
+	for _, feature := range allowed {
+		if _, found := index[feature]; !found {
+			if _, found := denied[feature]; !found {
+				unlicensed = append(unlicensed, feature)
+			}
+		}
+	}
+	sort.Strings(unlicensed)
+	missingFeatureCount.WithLabelValues(member.Key).Set(float64(len(unlicensed)))
+	deniedFeatureCount.WithLabelValues(member.Key).Set(float64(len(denied)))
+	deniedCond := metav1.Condition{
+		Type:    ConditionFeaturesDenied,
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoFeaturesDenied",
+		Message: "all claimed features are allowed by LicensePolicies",
+	}
+	if len(denied) > 0 {
+		deniedCond.Status = metav1.ConditionTrue
+		deniedCond.Reason = "DeniedByLicensePolicy"
+		deniedCond.Message = deniedMessage(denied)
+	}
+	conditions = append(conditions, deniedCond, licensesAcquiredCondition(unlicensed, earliestExpired))
+	errList = append(errList, r.setAddonConditions(ctx, clusterName, conditions...))
+	if earliestExpired.IsZero() {
+		earliestExpiry.DeleteLabelValues(member.Key)
 	} else {
-		errList = append(errList, r.Create(context.TODO(), &sec))
+		earliestExpiry.WithLabelValues(member.Key).Set(float64(earliestExpired.Unix()))
+	}
+	if len(index) > 0 {
+		indexBytes, err := json.Marshal(index)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		data[common.LicenseIndexKey] = indexBytes
+	}
+	unchanged := secretExists &&
+		sec.Annotations[common.ClusterUIDAnnotation] == cid &&
+		maps.EqualFunc(sec.Data, data, bytes.Equal)
+	var removed []string
+	for key := range sec.Data {
+		if _, found := data[key]; !found {
+			removed = append(removed, key)
+		}
+	}
+	sec.Data = data
+	if sec.Annotations == nil {
+		sec.Annotations = map[string]string{}
+	}
+	sec.Annotations[common.ClusterUIDAnnotation] = cid
+
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		klog.InfoS("pruning licenses", "clusterName", clusterName, "clusterUID", cid, "keys", removed)
+		prunedLicenses.WithLabelValues(member.Key).Add(float64(len(removed)))
+		if r.Recorder != nil {
+			r.Recorder.Eventf(cluster, core.EventTypeNormal, "LicensesPruned", "removed %s from secret %s/%s", strings.Join(removed, ","), sec.Namespace, sec.Name)
+		}
 	}
 
+	if !unchanged {
+		if secretExists {
+			err = r.Update(ctx, &sec)
+		} else {
+			err = r.Create(ctx, &sec)
+		}
+		if err == nil {
+			secretUpdates.WithLabelValues(member.Key).Inc()
+		}
+		errList = append(errList, err)
+	}
+
+	status := hubapi.LicenseInventoryStatus{
+		ClusterUID:         cid,
+		ClaimedFeatures:    sets.List(sets.New(features...)),
+		DeniedFeatures:     sets.List(sets.KeySet(denied)),
+		UnlicensedFeatures: unlicensed,
+		Licenses:           licenses,
+	}
+	slices.SortFunc(status.Licenses, func(a, b hubapi.LicenseSummary) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	if !earliestExpired.IsZero() {
+		status.EarliestExpiry = &metav1.Time{Time: earliestExpired}
+	}
+	for _, err := range issuerErrs {
+		status.AcquisitionErrors = append(status.AcquisitionErrors, err.Error())
+	}
+	errList = append(errList, r.updateInventory(ctx, member, status, issued))
+
 	if !earliestExpired.IsZero() {
+		requeueAfter := time.Until(earliestExpired.Add(-ttl))
+		if r.EnableInventory {
+			requeueAfter = min(requeueAfter, nextExpiryWindowChange(earliestExpired))
+		}
 		return reconcile.Result{
-			RequeueAfter: time.Until(earliestExpired.Add(-ttl)),
+			RequeueAfter: requeueAfter,
 		}, utilerrors.NewAggregate(errList)
 	}
 	return reconcile.Result{}, utilerrors.NewAggregate(errList)
 }
 
-func (r *LicenseAcquirer) getNewLicense(cid string, features []string) (*v1alpha1.License, *v1alpha1.Contract, error) {
-	lc, err := pc.NewClient(r.BaseURL, r.Token, cid, r.CaCert, r.InsecureSkipTLSVerify, fmt.Sprintf("license-proxyserver-manager/%s", v.Version.Version))
+func (r *LicenseAcquirer) addFinalizer(ctx context.Context, obj client.Object) error {
+	if controllerutil.ContainsFinalizer(obj, common.LicenseCleanupFinalizer) {
+		return nil
+	}
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.AddFinalizer(obj, common.LicenseCleanupFinalizer)
+	return r.Patch(ctx, obj, patch)
+}
+
+// cleanup drops the license state of a removed cluster from the hub.
+func (r *LicenseAcquirer) cleanup(ctx context.Context, member *fleet.Member) error {
+	cluster := member.Object
+	if !controllerutil.ContainsFinalizer(cluster, common.LicenseCleanupFinalizer) {
+		return nil
+	}
+
+	cids := sets.New[string]()
+	if member.UID != "" {
+		cids.Insert(member.UID)
+	}
+	var sec core.Secret
+	err := r.Get(ctx, r.Fleet.LicenseSecret(member), &sec)
+	if err == nil {
+		if cid := sec.Annotations[common.ClusterUIDAnnotation]; cid != "" {
+			cids.Insert(cid)
+		}
+		if err := r.Delete(ctx, &sec); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	for _, cid := range sets.List(cids) {
+		if err := r.forgetCluster(cid); err != nil {
+			return err
+		}
+	}
+	if err := r.deleteInventory(ctx, member.Key); err != nil {
+		return err
+	}
+	deleteClusterMetrics(member.Key)
+	klog.InfoS("removed license state", "clusterName", member.Name, "clusterUIDs", sets.List(cids))
+
+	patch := client.MergeFrom(cluster.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(cluster, common.LicenseCleanupFinalizer)
+	return r.Patch(ctx, cluster, patch)
+}
+
+// forgetCluster drops the in-memory registry, issuer client and cache dir of a cluster UID.
+func (r *LicenseAcquirer) forgetCluster(cid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.LicenseCache, cid)
+	delete(r.clients, cid)
+	return os.RemoveAll(filepath.Join(r.CacheDir, cid))
+}
+
+func missingFeatures(reg *storage.LicenseRegistry, features []string) sets.Set[string] {
+	missing := sets.New[string]()
+	for _, feature := range features {
+		if _, found := reg.LicenseForFeature(feature); !found {
+			missing.Insert(feature)
+		}
+	}
+	return missing
+}
+
+// acquireLicenses requests all missing features from the issuer in one call and keeps
+// asking for the features left uncovered, as one license covers a single product only.
+// If the issuer rejects a batch, features are requested one by one, so that a single
+// bad feature does not block the rest.
+func (r *LicenseAcquirer) acquireLicenses(key, cid string, creds *IssuerCredentials, reg *storage.LicenseRegistry, missing sets.Set[string]) []error {
+	var batchErr error
+	for missing.Len() > 0 {
+		l, err := r.acquireLicense(key, cid, creds, reg, sets.List(missing))
+		if err != nil {
+			batchErr = err
+			break
+		}
+		if !missing.HasAny(l.Features...) {
+			break
+		}
+		missing.Delete(l.Features...)
+	}
+	if missing.Len() == 0 {
+		return nil
+	}
+	if batchErr != nil && (missing.Len() == 1 || retriable(batchErr)) {
+		return []error{batchErr}
+	}
+
+	var errList []error
+	for _, feature := range sets.List(missing) {
+		if _, found := reg.LicenseForFeature(feature); found {
+			continue
+		}
+		if _, err := r.acquireLicense(key, cid, creds, reg, []string{feature}); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	return errList
+}
+
+func (r *LicenseAcquirer) acquireLicense(key, cid string, creds *IssuerCredentials, reg *storage.LicenseRegistry, features []string) (*v1alpha1.License, error) {
+	start := time.Now()
+	l, c, anchor, err := r.getNewLicense(cid, creds, features)
+	issuerRequestDuration.WithLabelValues(key).Observe(time.Since(start).Seconds())
 	if err != nil {
-		return nil, nil, err
+		issuerErrors.WithLabelValues(key).Inc()
+		klog.ErrorS(err, "failed to get new license", "member", key, "features", features)
+		return nil, err
 	}
+	klog.InfoS("acquired new license",
+		"member", key,
+		"clusterUID", cid,
+		"licenseID", l.ID,
+		"product", l.ProductLine,
+		"plan", l.PlanName,
+		"expiry", l.NotAfter.UTC().Format(time.RFC822),
+		"anchor", anchor,
+	)
+	reg.Add(l, c, anchor)
+	return l, nil
+}
 
-	lbytes, con, err := lc.AcquireLicense(features)
+// retriable returns true if the issuer failed for reasons unrelated to the requested features.
+// Client errors other than credential failures, request timeouts and throttling are terminal.
+// An expired or revoked token fails every request, so it must not split the batch and the
+// cluster is requeued with backoff until the credentials are fixed.
+func retriable(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return true
+	}
+	switch code := status.Status().Code; {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden,
+		code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	case code >= 400 && code < 500:
+		return false
+	}
+	return true
+}
+
+func ignoreInvalidCertificate(errList []error) []error {
+	var out []error
+	for _, err := range errList {
+		if !isInvalidCertificate(err) {
+			out = append(out, err)
+		}
+	}
+	return out
+}
+
+// getIssuerClient returns the issuer client of a cluster, which is recreated if the credentials
+// used for the cluster change.
+func (r *LicenseAcquirer) getIssuerClient(cid string, creds *IssuerCredentials) (*pc.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if lc, found := r.clients[cid]; found && lc.source == creds.source {
+		return lc.Client, nil
+	}
+	lc, err := pc.NewClient(creds.BaseURL, creds.Token, cid, creds.CaCert, creds.InsecureSkipTLSVerify, fmt.Sprintf("license-proxyserver-manager/%s", v.Version.Version))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if r.clients == nil {
+		r.clients = map[string]issuerClient{}
 	}
+	r.clients[cid] = issuerClient{Client: lc, source: creds.source}
+	return lc, nil
+}
 
-	caData, err := info.LoadLicenseCA()
+func (r *LicenseAcquirer) getNewLicense(cid string, creds *IssuerCredentials, features []string) (*v1alpha1.License, *v1alpha1.Contract, string, error) {
+	lc, err := r.getIssuerClient(cid, creds)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
-	caCert, err := info.ParseCertificate(caData)
+
+	lbytes, con, err := lc.AcquireLicense(features)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
-	l, err := verifier.ParseLicense(verifier.ParserOptions{
-		ClusterUID: cid,
-		CACert:     caCert,
-		License:    lbytes,
-	})
+	l, anchor, err := r.CABundle.ParseLicense(cid, lbytes)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
-	return &l, con, nil
+	return &l, con, anchor, nil
 }
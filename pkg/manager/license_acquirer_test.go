@@ -0,0 +1,113 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestRetriable(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, true},
+		{http.StatusForbidden, true},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		err := apierrors.NewGenericServerResponse(tt.code, "POST", schema.GroupResource{}, "", "", 0, false)
+		if got := retriable(err); got != tt.want {
+			t.Errorf("retriable(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestAcquireLicensesSplitsRejectedBatch(t *testing.T) {
+	iss, err := devissuer.New(devissuer.DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := trust.NewBundle(iss.CACertPEM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the issuer rejects any request for the unknown feature
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") == "Bearer revoked" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Features []string `json:"features"`
+		}
+		_ = json.Unmarshal(body, &req)
+		if slices.Contains(req.Features, "unknown") {
+			http.Error(w, "unknown feature", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		iss.Handler().ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	r := &LicenseAcquirer{CABundle: bundle}
+	creds := &IssuerCredentials{BaseURL: srv.URL, Token: "token"}
+	reg := storage.NewLicenseRegistry(t.TempDir(), ttl, nil)
+
+	errs := r.acquireLicenses("c1", clusterUID, creds, reg, sets.New("kubedb", "unknown"))
+	if len(errs) != 1 || retriable(errs[0]) {
+		t.Errorf("acquireLicenses() = %v, want a terminal error for the unknown feature", errs)
+	}
+	if _, found := reg.LicenseForFeature("kubedb"); !found {
+		t.Error("license for the remaining feature was not acquired")
+	}
+
+	// a revoked token fails the batch once, without a request per feature
+	calls = 0
+	creds = &IssuerCredentials{BaseURL: srv.URL, Token: "revoked", source: "revoked"}
+	reg = storage.NewLicenseRegistry(t.TempDir(), ttl, nil)
+	errs = r.acquireLicenses("c1", clusterUID, creds, reg, sets.New("kubedb", "kubestash", "unknown"))
+	if len(errs) != 1 || !retriable(errs[0]) {
+		t.Errorf("acquireLicenses() = %v, want a single retriable error for the revoked token", errs)
+	}
+	if calls != 1 {
+		t.Errorf("issuer called %d times, want 1", calls)
+	}
+}
@@ -20,19 +20,26 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
 	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
 	"go.bytebuilders.dev/license-proxyserver/pkg/manager/rbac"
 	"go.bytebuilders.dev/license-proxyserver/pkg/secretfs"
 	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gomodules.xyz/cert"
-	"gomodules.xyz/cert/certstore"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/rest"
 	"k8s.io/component-base/version"
 	"k8s.io/klog/v2"
@@ -41,10 +48,13 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	cmdfactory "open-cluster-management.io/addon-framework/pkg/cmd/factory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
 	"open-cluster-management.io/api/addon/v1alpha1"
+	addonclient "open-cluster-management.io/api/client/addon/clientset/versioned"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -53,10 +63,10 @@ import (
 //go:embed all:agent-manifests
 var FS embed.FS
 
-func NewRegistrationOption(restConfig *rest.Config, kc client.Client, addonName, agentName string) *agent.RegistrationOption {
+func NewRegistrationOption(restConfig *rest.Config, kc client.Client, addonName, agentName string, approver *CSRApprover) *agent.RegistrationOption {
 	return &agent.RegistrationOption{
 		CSRConfigurations: agent.KubeClientSignerConfigurations(addonName, agentName),
-		CSRApproveCheck:   agent.ApprovalAllCSRs,
+		CSRApproveCheck:   approver.Approve,
 		PermissionConfig:  rbac.SetupPermission(restConfig, kc, agentName),
 		AgentInstallNamespace: func(addon *v1alpha1.ManagedClusterAddOn) (string, error) {
 			return common.AddonInstallationNamespace, nil
@@ -75,81 +85,208 @@ func NewManagerCommand() *cobra.Command {
 	cmd.Use = "manager"
 	cmd.Short = "Starts the license proxy addon manager"
 	opts.AddFlags(cmd.Flags())
+	cmd.AddCommand(NewUninstallCommand(NewManagerOptions()))
+	// leader election is done by the hub manager, so that metrics and health probes are served by every replica
+	_ = cmd.Flags().MarkDeprecated("enable-leader-election", "use --leader-elect instead")
+	cmd.PreRun = func(cmd *cobra.Command, _ []string) {
+		mapDeprecatedLeaderElection(cmd.Flags(), opts)
+	}
 
 	return cmd
 }
 
+// mapDeprecatedLeaderElection maps --enable-leader-election to --leader-elect and disables
+// the leader election of the addon-framework command, which would otherwise hold a second lease.
+func mapDeprecatedLeaderElection(fs *pflag.FlagSet, opts *ManagerOptions) {
+	f := fs.Lookup("enable-leader-election")
+	if f == nil || f.Value.String() != "true" {
+		return
+	}
+	opts.LeaderElect = true
+	_ = f.Value.Set("false")
+}
+
 func runManagerController(ctx context.Context, cfg *rest.Config, opts *ManagerOptions) error {
 	log.SetLogger(klog.NewKlogr())
+	if errs := opts.Validate(); len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
 	resyncPeriod := 1 * time.Hour
+	leaderElectionNamespace := opts.LeaderElectionNamespace
+	if leaderElectionNamespace == "" {
+		leaderElectionNamespace = common.Namespace()
+	}
 
 	hubManager, err := ctrl.NewManager(cfg, manager.Options{
-		Scheme:                 scheme,
-		Metrics:                metricsserver.Options{BindAddress: ""},
-		HealthProbeBindAddress: "",
-		LeaderElection:         false,
-		LeaderElectionID:       "5b87adeb.mager.licenses.appscode.com",
-		NewClient:              cu.NewClient,
-		Cache: cache.Options{
-			SyncPeriod: &resyncPeriod,
-		},
+		Scheme:                  scheme,
+		Metrics:                 metricsserver.Options{BindAddress: opts.MetricsBindAddress},
+		HealthProbeBindAddress:  opts.HealthProbeBindAddress,
+		LeaderElection:          opts.LeaderElect,
+		LeaderElectionID:        "5b87adeb.mager.licenses.appscode.com",
+		LeaderElectionNamespace: leaderElectionNamespace,
+		// release the lease on shutdown, so that another replica takes over without waiting for it to expire
+		LeaderElectionReleaseOnCancel: true,
+		NewClient:                     cu.NewClient,
+		Cache:                         cacheOptions(resyncPeriod),
 	})
 	if err != nil {
 		return err
 	}
 
+	caBundle, err := trust.LoadBundle(opts.LicenseCAFile)
+	if err != nil {
+		return err
+	}
+
+	issuer, err := opts.IssuerCredentials()
+	if err != nil {
+		return err
+	}
+
+	acquirer := &LicenseAcquirer{
+		Client:       hubManager.GetClient(),
+		Fleet:        newFleetHub(hubManager.GetClient(), opts),
+		Issuer:       issuer,
+		CacheDir:     opts.CacheDir,
+		CABundle:     caBundle,
+		Recorder:     hubManager.GetEventRecorderFor("license-proxyserver-manager"),
+		LicenseCache: map[string]*storage.LicenseRegistry{},
+	}
+	if err := acquirer.SetupWithManager(hubManager); err != nil {
+		klog.Error(err, "unable to register LicenseAcquirer")
+		os.Exit(1)
+	}
+
+	// ClusterProfiles are served next to the members of the configured backend
+	if found, err := crdInstalled(hubManager, fleet.ClusterProfileGVK); err != nil {
+		return err
+	} else if found {
+		profileAcquirer := &LicenseAcquirer{
+			Client: hubManager.GetClient(),
+			Fleet: &fleet.ClusterProfileHub{
+				Client:  hubManager.GetClient(),
+				SkipOCM: opts.FleetBackend == fleet.BackendOCM,
+			},
+			Issuer:       issuer,
+			CacheDir:     opts.CacheDir,
+			CABundle:     caBundle,
+			Recorder:     hubManager.GetEventRecorderFor("license-proxyserver-manager"),
+			LicenseCache: map[string]*storage.LicenseRegistry{},
+		}
+		if err := profileAcquirer.SetupWithManager(hubManager); err != nil {
+			klog.Error(err, "unable to register LicenseAcquirer for ClusterProfiles")
+			os.Exit(1)
+		}
+	} else {
+		klog.InfoS("ClusterProfile CRD not found, ClusterProfiles are not served")
+	}
+
+	if err := hubManager.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+	if err := hubManager.AddReadyzCheck("informer-sync", func(req *http.Request) error {
+		if !hubManager.GetCache().WaitForCacheSync(req.Context()) {
+			return errors.New("informer caches are not synced")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if opts.FleetBackend == fleet.BackendOCM {
+		if err := setupAddonManager(cfg, hubManager, opts, issuer); err != nil {
+			return err
+		}
+	}
+
+	return hubManager.Start(ctx)
+}
+
+// newFleetHub returns the hub of the configured fleet backend.
+func newFleetHub(kc client.Client, opts *ManagerOptions) fleet.Hub {
+	if opts.FleetBackend == fleet.BackendShared {
+		return &fleet.SharedHub{Client: kc, Namespace: opts.FleetNamespace}
+	}
+	return &fleet.OCMHub{Client: kc}
+}
+
+// cacheOptions limits the ConfigMaps cached to the member ConfigMaps of the shared fleet backend
+// and the claims of ClusterProfiles.
+func cacheOptions(resyncPeriod time.Duration) cache.Options {
+	out := cache.Options{
+		SyncPeriod: &resyncPeriod,
+	}
+	member, _ := labels.NewRequirement(fleet.MemberLabel, selection.Exists, nil)
+	out.ByObject = map[client.Object]cache.ByObject{
+		&core.ConfigMap{}: {
+			Label: labels.NewSelector().Add(*member),
+		},
+	}
+	return out
+}
+
+// setupAddonManager deploys the license proxy to OCM ManagedClusters using the addon framework.
+func setupAddonManager(cfg *rest.Config, hubManager manager.Manager, opts *ManagerOptions, issuer *IssuerCredentials) error {
 	agentCertSecretFS := secretfs.New(hubManager.GetClient(), types.NamespacedName{
 		Name:      common.AgentConfigSecretName,
 		Namespace: common.Namespace(),
 	})
-	cs := certstore.New(agentCertSecretFS, "", common.Duration10Yrs)
-	if err := hubManager.Add(manager.RunnableFunc(func(ctx context.Context) error {
-		err = cs.InitCA()
-		if err != nil {
-			return err
-		}
-
-		_, _, err := cs.GetServerCertPair(common.ServerCertName, cert.AltNames{
+	var addonManager addonmanager.AddonManager
+	certs := &AgentCertRotator{
+		FS:           agentCertSecretFS,
+		CAValidity:   opts.AgentCAValidity,
+		CertValidity: opts.AgentCertValidity,
+		AltNames: cert.AltNames{
 			DNSNames: []string{
 				fmt.Sprintf("%s.%s", common.AgentName, common.AddonInstallationNamespace),
 				fmt.Sprintf("%s.%s.svc", common.AgentName, common.AddonInstallationNamespace),
 			},
-		})
-		return err
-	})); err != nil {
+		},
+		CheckInterval: time.Hour,
+		// the agents are updated with the renewed certificates by the addon manager
+		OnRotate: func(ctx context.Context) {
+			var list v1alpha1.ManagedClusterAddOnList
+			if err := hubManager.GetClient().List(ctx, &list); err != nil {
+				klog.ErrorS(err, "failed to list addons to roll out renewed certificates")
+				return
+			}
+			for _, addon := range list.Items {
+				if addon.Name == common.AddonName {
+					addonManager.Trigger(addon.Namespace, addon.Name)
+				}
+			}
+		},
+	}
+	// runs only on the leader
+	if err := hubManager.Add(certs); err != nil {
 		klog.Error(err, "unable to initialize cert store")
 		os.Exit(1)
 	}
 
-	var caCert []byte
-	if opts.CAFile != "" {
-		caCert, err = os.ReadFile(opts.CAFile)
-		if err != nil {
-			return errors.Wrapf(err, "failed to read CA file %s", opts.CAFile)
-		}
-	}
-	if err := (&LicenseAcquirer{
-		Client:                hubManager.GetClient(),
-		BaseURL:               opts.BaseURL,
-		Token:                 opts.Token,
-		CaCert:                caCert,
-		InsecureSkipTLSVerify: opts.InsecureSkipTLSVerify,
-		CacheDir:              opts.CacheDir,
-		LicenseCache:          map[string]*storage.LicenseRegistry{},
-	}).SetupWithManager(hubManager); err != nil {
-		klog.Error(err, "unable to register LicenseAcquirer")
-		os.Exit(1)
+	clusterSelector, err := opts.csrApprovalClusterSelector()
+	if err != nil {
+		return err
 	}
+	registrationOption := NewRegistrationOption(cfg, hubManager.GetClient(), common.AddonName, common.AgentName, &CSRApprover{
+		Client:          hubManager.GetClient(),
+		Recorder:        hubManager.GetEventRecorderFor("license-proxyserver-manager"),
+		AgentName:       common.AgentName,
+		ClusterSelector: clusterSelector,
+		ClusterSets:     opts.CSRApprovalClusterSets,
+	})
 
-	registrationOption := NewRegistrationOption(cfg, hubManager.GetClient(), common.AddonName, common.AgentName)
-
-	addonManager, err := addonmanager.New(cfg)
+	addonManager, err = addonmanager.New(cfg)
+	if err != nil {
+		return err
+	}
+	addonClient, err := addonclient.NewForConfig(cfg)
 	if err != nil {
 		return err
 	}
 	agent, err := addonfactory.NewAgentAddonFactory(common.AddonName, FS, common.AgentManifestsDir).
 		WithScheme(scheme).
-		WithGetValuesFuncs(GetConfigValues(hubManager.GetClient(), opts, cs)).
+		WithConfigGVRs(utils.AddOnDeploymentConfigGVR).
+		WithGetValuesFuncs(GetConfigValues(hubManager.GetClient(), opts, issuer, certs, utils.NewAddOnDeploymentConfigGetter(addonClient))).
 		WithAgentRegistrationOption(registrationOption).
 		WithAgentHealthProber(agentHealthProber()).
 		WithAgentInstallNamespace(func(addon *v1alpha1.ManagedClusterAddOn) (string, error) {
@@ -165,8 +302,21 @@ func runManagerController(ctx context.Context, cfg *rest.Config, opts *ManagerOp
 		return err
 	}
 
-	go func() {
-		_ = addonManager.Start(ctx)
-	}()
-	return hubManager.Start(ctx)
+	// runs only on the leader, along with the controllers of the hub manager
+	if err := hubManager.Add(manager.RunnableFunc(addonManager.Start)); err != nil {
+		return err
+	}
+
+	return hubManager.AddReadyzCheck("cert-store", func(_ *http.Request) error {
+		// the cert store is initialized by the leader only
+		select {
+		case <-hubManager.Elected():
+		default:
+			return nil
+		}
+		if !certs.Ready() {
+			return errors.New("cert store is not initialized")
+		}
+		return nil
+	})
 }
@@ -0,0 +1,37 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"testing"
+)
+
+func TestDeprecatedLeaderElectionFlag(t *testing.T) {
+	cmd := NewManagerCommand()
+	if err := cmd.ParseFlags([]string{"--enable-leader-election"}); err != nil {
+		t.Fatal(err)
+	}
+	opts := NewManagerOptions()
+	mapDeprecatedLeaderElection(cmd.Flags(), opts)
+
+	if !opts.LeaderElect {
+		t.Error("--enable-leader-election did not enable --leader-elect")
+	}
+	if v := cmd.Flags().Lookup("enable-leader-election").Value.String(); v != "false" {
+		t.Errorf("addon-framework leader election = %s, want false", v)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "license_proxyserver_manager"
+
+var (
+	prunedLicenses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "pruned_licenses_total",
+			Help:      "Number of entries removed from the license secret of a cluster",
+		},
+		[]string{"cluster"},
+	)
+	missingFeatureCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "cluster_missing_features",
+			Help:      "Number of features claimed by a cluster without a valid license",
+		},
+		[]string{"cluster"},
+	)
+	deniedFeatureCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "cluster_denied_features",
+			Help:      "Number of features claimed by a cluster that are denied by LicensePolicies",
+		},
+		[]string{"cluster"},
+	)
+	issuerRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "issuer_request_duration_seconds",
+			Help:      "Latency of license issuer requests made for a cluster",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"cluster"},
+	)
+	issuerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "issuer_errors_total",
+			Help:      "Number of failed license issuer requests made for a cluster",
+		},
+		[]string{"cluster"},
+	)
+	secretUpdates = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "license_secret_updates_total",
+			Help:      "Number of writes to the license secret of a cluster",
+		},
+		[]string{"cluster"},
+	)
+	earliestExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "cluster_license_earliest_expiry_timestamp_seconds",
+			Help:      "Unix time of the earliest expiring license synced to a cluster",
+		},
+		[]string{"cluster"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		prunedLicenses,
+		missingFeatureCount,
+		deniedFeatureCount,
+		issuerRequestDuration,
+		issuerErrors,
+		secretUpdates,
+		earliestExpiry,
+	)
+}
+
+// deleteClusterMetrics deletes the metrics of a removed member, which are labeled by the member key.
+func deleteClusterMetrics(key string) {
+	prunedLicenses.DeleteLabelValues(key)
+	missingFeatureCount.DeleteLabelValues(key)
+	deniedFeatureCount.DeleteLabelValues(key)
+	issuerRequestDuration.DeleteLabelValues(key)
+	issuerErrors.DeleteLabelValues(key)
+	secretUpdates.DeleteLabelValues(key)
+	earliestExpiry.DeleteLabelValues(key)
+}
@@ -17,7 +17,15 @@ limitations under the License.
 package manager
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+
 	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ManagerOptions struct {
@@ -26,11 +34,34 @@ type ManagerOptions struct {
 	Token                 string
 	CAFile                string
 	InsecureSkipTLSVerify bool
+	LicenseCAFile         string
 	CacheDir              string
+
+	LeaderElect             bool
+	LeaderElectionNamespace string
+
+	MetricsBindAddress     string
+	HealthProbeBindAddress string
+
+	FleetBackend   string
+	FleetNamespace string
+
+	CSRApprovalClusterSelector string
+	CSRApprovalClusterSets     []string
+
+	AgentCAValidity   time.Duration
+	AgentCertValidity time.Duration
 }
 
 func NewManagerOptions() *ManagerOptions {
-	return &ManagerOptions{}
+	return &ManagerOptions{
+		// disabled unless the deployment exposes the ports, as the addon-framework command serves :8443 already
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+		FleetBackend:           fleet.BackendOCM,
+		AgentCAValidity:        365 * 24 * time.Hour,
+		AgentCertValidity:      30 * 24 * time.Hour,
+	}
 }
 
 func (s *ManagerOptions) AddFlags(fs *pflag.FlagSet) {
@@ -39,9 +70,61 @@ func (s *ManagerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&s.Token, "token", s.Token, "License server token")
 	fs.StringVar(&s.CAFile, "ca-file", s.CAFile, "Path to custom CA cert file used to issue appscode.com cert")
 	fs.BoolVar(&s.InsecureSkipTLSVerify, "insecure-skip-tls-verify", s.InsecureSkipTLSVerify, "If true, skips verifying appscode.com cert")
+	fs.StringVar(&s.LicenseCAFile, "license-ca-file", s.LicenseCAFile, "Path to CA bundle used to verify licenses. May contain multiple certificates. Defaults to the AppsCode license CA")
 	fs.StringVar(&s.CacheDir, "cache-dir", s.CacheDir, "Path to license cache directory")
+	fs.BoolVar(&s.LeaderElect, "leader-elect", s.LeaderElect, "If true, elects a leader among manager replicas. Only the leader acquires licenses and runs the addon manager")
+	fs.StringVar(&s.MetricsBindAddress, "metrics-bind-address", s.MetricsBindAddress, "The address the metrics endpoint binds to, for example :8080. Set to 0 to disable")
+	fs.StringVar(&s.HealthProbeBindAddress, "health-probe-bind-address", s.HealthProbeBindAddress, "The address the health probe endpoint binds to, for example :8081. Set to 0 to disable")
+	fs.StringVar(&s.LeaderElectionNamespace, "leader-election-namespace", s.LeaderElectionNamespace, "Namespace of the leader election lease. Defaults to the manager namespace")
+	fs.StringVar(&s.FleetBackend, "fleet-backend", s.FleetBackend, "Backend used to serve member clusters. One of ocm or shared")
+	fs.StringVar(&s.FleetNamespace, "fleet-namespace", s.FleetNamespace, "Hub namespace shared by the clusters of the fleet. Used by the shared fleet backend")
+	fs.StringVar(&s.CSRApprovalClusterSelector, "csr-approval-cluster-selector", s.CSRApprovalClusterSelector, "Label selector of the ManagedClusters whose addon agent CSRs are approved. Defaults to all clusters")
+	fs.StringSliceVar(&s.CSRApprovalClusterSets, "csr-approval-cluster-sets", s.CSRApprovalClusterSets, "ManagedClusterSets whose members get their addon agent CSRs approved. Defaults to all clusters")
+	fs.DurationVar(&s.AgentCAValidity, "agent-ca-validity", s.AgentCAValidity, "Validity of the CA issuing the serving certificates of the addon agents. It is renewed when a third of it is left")
+	fs.DurationVar(&s.AgentCertValidity, "agent-cert-validity", s.AgentCertValidity, "Validity of the serving certificates of the addon agents. They are renewed when a third of it is left")
+}
+
+// IssuerCredentials returns the issuer credentials set by flags.
+func (s *ManagerOptions) IssuerCredentials() (*IssuerCredentials, error) {
+	creds := &IssuerCredentials{
+		BaseURL:               s.BaseURL,
+		Token:                 s.Token,
+		InsecureSkipTLSVerify: s.InsecureSkipTLSVerify,
+	}
+	if s.CAFile != "" {
+		caCert, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", s.CAFile, err)
+		}
+		creds.CaCert = caCert
+	}
+	return creds, nil
 }
 
 func (s *ManagerOptions) Validate() []error {
-	return nil
+	var errs []error
+	switch s.FleetBackend {
+	case fleet.BackendOCM:
+	case fleet.BackendShared:
+		if s.FleetNamespace == "" {
+			errs = append(errs, errors.New("--fleet-namespace is required by the shared fleet backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown --fleet-backend %q", s.FleetBackend))
+	}
+	// serving certificates must not outlive the CA
+	if s.AgentCertValidity < time.Hour || s.AgentCertValidity > s.AgentCAValidity/3 {
+		errs = append(errs, errors.New("--agent-cert-validity must be at least 1h and at most a third of --agent-ca-validity"))
+	}
+	if _, err := s.csrApprovalClusterSelector(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid --csr-approval-cluster-selector: %w", err))
+	}
+	return errs
+}
+
+func (s *ManagerOptions) csrApprovalClusterSelector() (*metav1.LabelSelector, error) {
+	if s.CSRApprovalClusterSelector == "" {
+		return nil, nil
+	}
+	return metav1.ParseToLabelSelector(s.CSRApprovalClusterSelector)
 }
@@ -0,0 +1,154 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterPolicies returns the LicensePolicies that select the cluster, sorted by name.
+func (r *LicenseAcquirer) clusterPolicies(ctx context.Context, cluster client.Object) ([]hubapi.LicensePolicy, error) {
+	if !r.EnforcePolicies {
+		return nil, nil
+	}
+
+	var list hubapi.LicensePolicyList
+	if err := r.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	var policies []hubapi.LicensePolicy
+	for _, p := range list.Items {
+		selected, err := selectsCluster(ctx, r.Client, p.Spec.ClusterSelector, p.Spec.ClusterSets, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate LicensePolicy %s: %w", p.Name, err)
+		}
+		if selected {
+			policies = append(policies, p)
+		}
+	}
+	slices.SortFunc(policies, func(a, b hubapi.LicensePolicy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return policies, nil
+}
+
+// selectsCluster returns true if the cluster matches the label selector and is a member of any of the
+// cluster sets, which are ManagedClusterSets for OCM ManagedClusters. Empty selectors match all clusters.
+func selectsCluster(ctx context.Context, kc client.Reader, selector *metav1.LabelSelector, clusterSets []string, cluster client.Object) (bool, error) {
+	if selector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return false, err
+		}
+		if !sel.Matches(labels.Set(cluster.GetLabels())) {
+			return false, nil
+		}
+	}
+	if len(clusterSets) == 0 {
+		return true, nil
+	}
+	for _, name := range clusterSets {
+		member, err := inClusterSet(ctx, kc, name, cluster)
+		if err != nil {
+			return false, err
+		}
+		if member {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func inClusterSet(ctx context.Context, kc client.Reader, name string, cluster client.Object) (bool, error) {
+	if _, ok := cluster.(*clusterv1.ManagedCluster); !ok {
+		// ManagedClusterSets only contain OCM ManagedClusters
+		return fleet.InClusterSet(cluster, name), nil
+	}
+	var set clusterv1beta2.ManagedClusterSet
+	err := kc.Get(ctx, client.ObjectKey{Name: name}, &set)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if set.Spec.ClusterSelector.SelectorType == clusterv1beta2.LabelSelector {
+		if set.Spec.ClusterSelector.LabelSelector == nil {
+			return false, nil
+		}
+		sel, err := metav1.LabelSelectorAsSelector(set.Spec.ClusterSelector.LabelSelector)
+		if err != nil {
+			return false, err
+		}
+		return sel.Matches(labels.Set(cluster.GetLabels())), nil
+	}
+	return cluster.GetLabels()[clusterv1beta2.ClusterSetLabel] == name, nil
+}
+
+// deniedFeatures returns the features denied by any of the policies, by name or product line,
+// mapped to the first policy denying it. Denied features are not requested from the issuer.
+func deniedFeatures(policies []hubapi.LicensePolicy, features []string) map[string]string {
+	denied := map[string]string{}
+	for _, feature := range features {
+		for _, p := range policies {
+			if !p.Spec.AllowsFeature(feature) {
+				denied[feature] = p.Name
+				break
+			}
+		}
+	}
+	return denied
+}
+
+// licenseDeniedBy returns the first policy that does not allow the tier of the license.
+func licenseDeniedBy(policies []hubapi.LicensePolicy, l v1alpha1.License) (string, bool) {
+	for _, p := range policies {
+		if !p.Spec.AllowsLicense(l) {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+func deniedMessage(denied map[string]string) string {
+	byPolicy := map[string][]string{}
+	for feature, policy := range denied {
+		byPolicy[policy] = append(byPolicy[policy], feature)
+	}
+	msgs := make([]string, 0, len(byPolicy))
+	for _, policy := range sets.List(sets.KeySet(byPolicy)) {
+		features := byPolicy[policy]
+		slices.Sort(features)
+		msgs = append(msgs, fmt.Sprintf("%s denied by LicensePolicy %s", strings.Join(features, ","), policy))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -21,7 +21,9 @@ import (
 
 	"go.bytebuilders.dev/license-proxyserver/pkg/common"
 
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -59,9 +61,17 @@ func SetupPermission(restConfig *rest.Config, kc client.Client, agentName string
 			},
 			Rules: []rbacv1.PolicyRule{
 				{
-					APIGroups: []string{""},
-					Verbs:     []string{"get", "list", "watch"},
-					Resources: []string{"secrets"},
+					// the agent caches the license secret using a field selector on its name
+					APIGroups:     []string{""},
+					Verbs:         []string{"get", "list", "watch"},
+					Resources:     []string{"secrets"},
+					ResourceNames: []string{common.LicenseSecret},
+				},
+				{
+					APIGroups:     []string{""},
+					Verbs:         []string{"get", "update", "patch"},
+					Resources:     []string{"secrets"},
+					ResourceNames: []string{common.LicenseUsageSecret},
 				},
 			},
 		}
@@ -80,8 +90,9 @@ func SetupPermission(restConfig *rest.Config, kc client.Client, agentName string
 				},
 			},
 			RoleRef: rbacv1.RoleRef{
-				Kind: "Role",
-				Name: addon.Name,
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     addon.Name,
 			},
 			Subjects: []rbacv1.Subject{
 				{
@@ -100,35 +111,84 @@ func SetupPermission(restConfig *rest.Config, kc client.Client, agentName string
 			if reg.Type == addonv1beta1.KubeClient && reg.KubeClient.Driver == "csr" {
 				roleBinding.Subjects = []rbacv1.Subject{
 					{
-						Kind: "User",
-						Name: agentUser,
+						APIGroup: rbacv1.GroupName,
+						Kind:     rbacv1.UserKind,
+						Name:     agentUser,
 					},
 				}
 			}
 		}
 
-		_, err = nativeClient.RbacV1().Roles(cluster.Name).Get(context.TODO(), role.Name, metav1.GetOptions{})
-		switch {
-		case apierrors.IsNotFound(err):
-			_, createErr := nativeClient.RbacV1().Roles(cluster.Name).Create(context.TODO(), role, metav1.CreateOptions{})
-			if createErr != nil {
-				return createErr
-			}
-		case err != nil:
+		if err := applyRole(context.TODO(), nativeClient, role); err != nil {
+			return err
+		}
+		if err := applyRoleBinding(context.TODO(), nativeClient, roleBinding); err != nil {
 			return err
 		}
 
-		_, err = nativeClient.RbacV1().RoleBindings(cluster.Name).Get(context.TODO(), roleBinding.Name, metav1.GetOptions{})
-		switch {
-		case apierrors.IsNotFound(err):
-			_, createErr := nativeClient.RbacV1().RoleBindings(cluster.Name).Create(context.TODO(), roleBinding, metav1.CreateOptions{})
-			if createErr != nil {
-				return createErr
-			}
-		case err != nil:
+		// the agent may only update the usage secret, so it is created here
+		usageSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            common.LicenseUsageSecret,
+				Namespace:       namespace,
+				OwnerReferences: role.OwnerReferences,
+			},
+		}
+		_, err = nativeClient.CoreV1().Secrets(cluster.Name).Create(context.TODO(), usageSecret, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// applyRole creates the role or updates its rules and owners.
+func applyRole(ctx context.Context, nc kubernetes.Interface, role *rbacv1.Role) error {
+	cur, err := nc.RbacV1().Roles(role.Namespace).Get(ctx, role.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = nc.RbacV1().Roles(role.Namespace).Create(ctx, role, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(cur.Rules, role.Rules) &&
+		equality.Semantic.DeepEqual(cur.OwnerReferences, role.OwnerReferences) {
+		return nil
+	}
+	cur.Rules = role.Rules
+	cur.OwnerReferences = role.OwnerReferences
+	_, err = nc.RbacV1().Roles(role.Namespace).Update(ctx, cur, metav1.UpdateOptions{})
+	return err
+}
+
+// applyRoleBinding creates the role binding or updates its subjects and owners.
+// The role ref of a role binding is immutable, so the binding is recreated if it changes.
+func applyRoleBinding(ctx context.Context, nc kubernetes.Interface, rb *rbacv1.RoleBinding) error {
+	cur, err := nc.RbacV1().RoleBindings(rb.Namespace).Get(ctx, rb.Name, metav1.GetOptions{})
+	if err == nil && cur.RoleRef != rb.RoleRef {
+		err = nc.RbacV1().RoleBindings(rb.Namespace).Delete(ctx, rb.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &cur.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
 			return err
 		}
+		err = apierrors.NewNotFound(rbacv1.Resource("rolebindings"), rb.Name)
+	}
+	if apierrors.IsNotFound(err) {
+		_, err = nc.RbacV1().RoleBindings(rb.Namespace).Create(ctx, rb, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
 
+	if equality.Semantic.DeepEqual(cur.Subjects, rb.Subjects) &&
+		equality.Semantic.DeepEqual(cur.OwnerReferences, rb.OwnerReferences) {
 		return nil
 	}
+	cur.Subjects = rb.Subjects
+	cur.OwnerReferences = rb.OwnerReferences
+	_, err = nc.RbacV1().RoleBindings(rb.Namespace).Update(ctx, cur, metav1.UpdateOptions{})
+	return err
 }
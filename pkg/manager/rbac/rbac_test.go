@@ -0,0 +1,64 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyRoleBinding(t *testing.T) {
+	ctx := context.TODO()
+	nc := fake.NewClientset(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon", Namespace: "c1"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "addon"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "agent"}},
+	})
+
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon", Namespace: "c1"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "addon"},
+		Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "agent-user"}},
+	}
+	if err := applyRoleBinding(ctx, nc, rb); err != nil {
+		t.Fatal(err)
+	}
+	cur, err := nc.RbacV1().RoleBindings("c1").Get(ctx, "addon", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(cur.Subjects, rb.Subjects) {
+		t.Errorf("subjects = %v, want %v", cur.Subjects, rb.Subjects)
+	}
+
+	rb.RoleRef.Name = "renamed"
+	if err := applyRoleBinding(ctx, nc, rb); err != nil {
+		t.Fatal(err)
+	}
+	cur, err = nc.RbacV1().RoleBindings("c1").Get(ctx, "addon", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cur.RoleRef != rb.RoleRef {
+		t.Errorf("role ref = %v, want %v", cur.RoleRef, rb.RoleRef)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// NewUninstallCommand returns the command run when the manager is uninstalled. It removes the
+// license cleanup finalizer from the fleet members, so that they can be deleted without the manager.
+func NewUninstallCommand(opts *ManagerOptions) *cobra.Command {
+	var kubeconfig string
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Removes the license cleanup finalizer from the fleet members",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+			if err != nil {
+				return err
+			}
+			kc, err := client.New(cfg, client.Options{Scheme: scheme})
+			if err != nil {
+				return err
+			}
+			hubs := []fleet.Hub{
+				newFleetHub(kc, opts),
+				&fleet.ClusterProfileHub{Client: kc},
+			}
+			return removeFinalizers(cmd.Context(), kc, hubs)
+		},
+	}
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", kubeconfig, "Path to the hub kubeconfig. Defaults to the in-cluster config")
+	cmd.Flags().StringVar(&opts.FleetBackend, "fleet-backend", opts.FleetBackend, "Backend used to serve member clusters. One of ocm or shared")
+	cmd.Flags().StringVar(&opts.FleetNamespace, "fleet-namespace", opts.FleetNamespace, "Hub namespace shared by the clusters of the fleet. Used by the shared fleet backend")
+	return cmd
+}
+
+// removeFinalizers removes the license cleanup finalizer from the members of the hubs.
+// Hubs whose member type is not installed are skipped.
+func removeFinalizers(ctx context.Context, kc client.Client, hubs []fleet.Hub) error {
+	var errList []error
+	for _, hub := range hubs {
+		members, err := hub.ListMembers(ctx)
+		if meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			errList = append(errList, err)
+			continue
+		}
+		for _, member := range members {
+			obj := member.Object
+			if !controllerutil.ContainsFinalizer(obj, common.LicenseCleanupFinalizer) {
+				continue
+			}
+			patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+			controllerutil.RemoveFinalizer(obj, common.LicenseCleanupFinalizer)
+			if err := kc.Patch(ctx, obj, patch); client.IgnoreNotFound(err) != nil {
+				errList = append(errList, err)
+				continue
+			}
+			klog.InfoS("removed license cleanup finalizer", "name", obj.GetName(), "namespace", obj.GetNamespace())
+		}
+	}
+	return utilerrors.NewAggregate(errList)
+}
@@ -18,15 +18,13 @@ package licenserequest
 
 import (
 	"context"
-	"crypto/x509"
-	"sort"
 	"strings"
 	"time"
 
 	proxyv1alpha1 "go.bytebuilders.dev/license-proxyserver/apis/proxyserver/v1alpha1"
-	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/clusterclaim"
 	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
-	verifier "go.bytebuilders.dev/license-verifier"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
 	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
 	pc "go.bytebuilders.dev/license-verifier/client"
 
@@ -34,22 +32,24 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/klog/v2"
-	clustermeta "kmodules.xyz/client-go/cluster"
-	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// MaxWaitTimeout caps the wait timeout of a LicenseRequest, so that
+// requests finish before the kube-apiserver times out proxying them.
+const MaxWaitTimeout = 50 * time.Second
+
 type Storage struct {
-	cid         string
-	caCert      *x509.Certificate
-	lc          *pc.Client
-	reg         *storage.LicenseRegistry
-	rb          *storage.RecordBook
-	spokeClient client.Client
+	cid      string
+	caBundle *trust.Bundle
+	lc       *pc.Client
+	reg      *storage.LicenseRegistry
+	rb       *storage.RecordBook
+	// fleetMember is true if licenses are acquired by the hub for the features in the claim
+	fleetMember bool
+	claims      *clusterclaim.Updater
 }
 
 var (
@@ -60,14 +60,15 @@ var (
 	_ rest.SingularNameProvider     = &Storage{}
 )
 
-func NewStorage(cid string, caCert *x509.Certificate, lc *pc.Client, reg *storage.LicenseRegistry, rb *storage.RecordBook, spokeClient client.Client) *Storage {
+func NewStorage(cid string, caBundle *trust.Bundle, lc *pc.Client, reg *storage.LicenseRegistry, rb *storage.RecordBook, fleetMember bool, claims *clusterclaim.Updater) *Storage {
 	s := &Storage{
 		cid:         cid,
-		caCert:      caCert,
+		caBundle:    caBundle,
 		lc:          lc,
 		reg:         reg,
 		rb:          rb,
-		spokeClient: spokeClient,
+		fleetMember: fleetMember,
+		claims:      claims,
 	}
 	return s
 }
@@ -95,69 +96,97 @@ func (r *Storage) Create(ctx context.Context, obj runtime.Object, _ rest.Validat
 	}
 	in := obj.(*proxyv1alpha1.LicenseRequest)
 
-	isSpokeCluster := clustermeta.IsOpenClusterSpoke(r.spokeClient)
-
 	l, err := r.getLicense(in.Request.Features)
 	if err != nil {
 		return nil, err
-	} else if l == nil && isSpokeCluster {
-		ca := clusterv1alpha1.ClusterClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: common.ClusterClaimLicense,
-			},
-		}
-		err = r.spokeClient.Get(context.TODO(), client.ObjectKey{Name: ca.Name}, &ca)
-		if err == nil {
-			curFeatures := sets.New[string](strings.Split(ca.Spec.Value, ",")...)
-			reqFeatures := sets.New[string](in.Request.Features...)
-
-			extraFeatures := reqFeatures.Difference(curFeatures)
-			if extraFeatures.Len() > 0 {
-				curFeatures.Insert(extraFeatures.UnsortedList()...)
-
-				ca.Spec.Value = strings.Join(sets.List[string](curFeatures), ",")
-				err = r.spokeClient.Update(context.TODO(), &ca)
-				if err != nil {
-					return nil, err
-				}
+	} else if l == nil && r.fleetMember {
+		// ask the hub for a license via the ClusterClaim
+		r.claims.Enqueue(in.Request.Features, true)
+
+		timeout := waitTimeout(in.Request.WaitTimeout)
+		if timeout <= 0 {
+			// return blank response instead of error
+			in.Response = &proxyv1alpha1.LicenseRequestResponse{
+				Result: proxyv1alpha1.LicenseRequestNotFound,
 			}
-		} else if apierrors.IsNotFound(err) {
-			reqFeatures := in.Request.Features
-			sort.Strings(reqFeatures)
-			ca.Spec.Value = strings.Join(reqFeatures, ",")
-			err = r.spokeClient.Create(context.TODO(), &ca)
-			if err != nil {
-				return nil, err
+			return in, nil
+		}
+
+		l = r.waitForLicense(ctx, in.Request.Features, timeout)
+		if l == nil {
+			in.Response = &proxyv1alpha1.LicenseRequestResponse{
+				Result: proxyv1alpha1.LicenseRequestTimedOut,
 			}
-		} else if err != nil {
-			return nil, err
+			return in, nil
 		}
+	}
 
-		// return blank response instead of error
-		in.Response = &proxyv1alpha1.LicenseRequestResponse{}
-		return in, nil
+	if r.fleetMember {
+		// keep the features in the ClusterClaim from being pruned
+		r.claims.Enqueue(in.Request.Features, false)
 	}
 
 	if l != nil {
 		r.rb.Record(l.ID, in.Request.Features, user)
 		in.Response = &proxyv1alpha1.LicenseRequestResponse{
 			License: string(l.Data),
+			Result:  proxyv1alpha1.LicenseRequestFound,
 		}
 	} else {
 		// return blank response instead of error
 		// typically license mounted via secret has expired
-		in.Response = &proxyv1alpha1.LicenseRequestResponse{}
+		in.Response = &proxyv1alpha1.LicenseRequestResponse{
+			Result: proxyv1alpha1.LicenseRequestNotFound,
+		}
 	}
 
 	return in, nil
 }
 
-func (r *Storage) getLicense(features []string) (*v1alpha1.License, error) {
+// findLicense returns a license from the registry for any of the features.
+func (r *Storage) findLicense(features []string) (*v1alpha1.License, bool) {
 	for _, feature := range features {
 		l, ok := r.reg.LicenseForFeature(feature)
 		if ok {
-			return l, nil
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// waitTimeout returns the requested wait timeout capped at MaxWaitTimeout.
+func waitTimeout(d *metav1.Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return min(d.Duration, MaxWaitTimeout)
+}
+
+// waitForLicense waits until a license for any of the features is added to the registry.
+// It returns nil if none is added within timeout.
+func (r *Storage) waitForLicense(ctx context.Context, features []string, timeout time.Duration) *v1alpha1.License {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		// get the channel before looking up the registry, so that no add is missed
+		added := r.reg.Added()
+		if l, ok := r.findLicense(features); ok {
+			return l
 		}
+		select {
+		case <-added:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (r *Storage) getLicense(features []string) (*v1alpha1.License, error) {
+	if l, ok := r.findLicense(features); ok {
+		return l, nil
 	}
 	if r.lc == nil {
 		return nil, nil
@@ -167,11 +196,7 @@ func (r *Storage) getLicense(features []string) (*v1alpha1.License, error) {
 	if err != nil {
 		return nil, err
 	}
-	l, err := verifier.ParseLicense(verifier.ParserOptions{
-		ClusterUID: r.cid,
-		CACert:     r.caCert,
-		License:    lbytes,
-	})
+	l, anchor, err := r.caBundle.ParseLicense(r.cid, lbytes)
 	if err != nil {
 		return nil, err
 	}
@@ -181,8 +206,9 @@ func (r *Storage) getLicense(features []string) (*v1alpha1.License, error) {
 		"product", l.ProductLine,
 		"plan", l.PlanName,
 		"expiry", l.NotAfter.UTC().Format(time.RFC822),
+		"anchor", anchor,
 	)
-	r.reg.Add(&l, c)
+	r.reg.Add(&l, c, anchor)
 	return &l, nil
 }
 
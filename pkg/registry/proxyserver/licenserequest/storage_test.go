@@ -0,0 +1,237 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package licenserequest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	proxyv1alpha1 "go.bytebuilders.dev/license-proxyserver/apis/proxyserver/v1alpha1"
+	"go.bytebuilders.dev/license-proxyserver/pkg/controllers/clusterclaim"
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+	"go.bytebuilders.dev/license-proxyserver/pkg/fleet"
+	"go.bytebuilders.dev/license-proxyserver/pkg/storage"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const clusterUID = "8d4bd39a-a1a4-4b2b-9d6b-2f0e5b1c3e7a"
+
+// fakeHub is a fleet member without OCM. Its hub acquires licenses for the
+// features added to the claim and syncs them to the registry of the cluster.
+type fakeHub struct {
+	iss    *devissuer.Issuer
+	bundle *trust.Bundle
+	reg    *storage.LicenseRegistry
+
+	mu       sync.Mutex
+	claim    *fleet.Claim
+	acquired sets.Set[string]
+	err      error
+}
+
+var _ fleet.Spoke = &fakeHub{}
+
+func (h *fakeHub) UpdateClaim(_ context.Context, mutate fleet.MutateFunc) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	exists := h.claim != nil
+	claim := h.claim
+	if !exists {
+		claim = &fleet.Claim{Features: sets.New[string](), LastRequested: map[string]metav1.Time{}}
+	}
+	changed, err := mutate(claim, exists)
+	if err != nil || !changed {
+		return err
+	}
+	h.claim = claim
+
+	if wanted := claim.Features.Difference(h.acquired); h.iss != nil && wanted.Len() > 0 {
+		data, _, err := h.iss.Issue(clusterUID, sets.List(wanted))
+		if err != nil {
+			h.err = err
+			return nil
+		}
+		l, anchor, err := h.bundle.ParseLicense(clusterUID, data)
+		if err != nil {
+			h.err = err
+			return nil
+		}
+		h.reg.Add(&l, nil, anchor)
+		h.acquired = h.acquired.Union(wanted)
+	}
+	return nil
+}
+
+func (h *fakeHub) LicenseSecret() client.ObjectKey {
+	return client.ObjectKey{}
+}
+
+func (h *fakeHub) FetchLicenses(_ context.Context, _ client.Reader) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (h *fakeHub) claimed(feature string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.claim != nil && h.claim.Features.Has(feature)
+}
+
+func TestCreateEnqueuesFeatureOfFleetMember(t *testing.T) {
+	rb := storage.NewRecordBook()
+	reg := storage.NewLicenseRegistry("", storage.MinRemainingLife, rb)
+	hub := &fakeHub{reg: reg, acquired: sets.New[string]()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	claims := clusterclaim.NewUpdater(hub)
+	go func() {
+		_ = claims.Start(ctx)
+	}()
+
+	s := NewStorage(clusterUID, nil, nil, reg, rb, true, claims)
+	in := &proxyv1alpha1.LicenseRequest{
+		Request: &proxyv1alpha1.LicenseRequestRequest{
+			Features: []string{"kubedb"},
+		},
+	}
+	reqCtx := request.WithUser(ctx, &user.DefaultInfo{Name: "kubedb-operator"})
+	obj, err := s.Create(reqCtx, in, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := obj.(*proxyv1alpha1.LicenseRequest).Response; resp == nil || resp.Result != proxyv1alpha1.LicenseRequestNotFound {
+		t.Errorf("response = %+v, want %s", resp, proxyv1alpha1.LicenseRequestNotFound)
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return hub.claimed("kubedb"), nil
+	})
+	if err != nil {
+		t.Errorf("feature was not added to the claim: %v", err)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	tests := []struct {
+		in   *metav1.Duration
+		want time.Duration
+	}{
+		{nil, 0},
+		{&metav1.Duration{Duration: -time.Second}, -time.Second},
+		{&metav1.Duration{Duration: 10 * time.Second}, 10 * time.Second},
+		{&metav1.Duration{Duration: time.Hour}, MaxWaitTimeout},
+	}
+	for _, tt := range tests {
+		if got := waitTimeout(tt.in); got != tt.want {
+			t.Errorf("waitTimeout(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTimesOutWaitingForLicense(t *testing.T) {
+	rb := storage.NewRecordBook()
+	reg := storage.NewLicenseRegistry("", storage.MinRemainingLife, rb)
+	// the hub never delivers a license
+	hub := &fakeHub{reg: reg, acquired: sets.New[string]()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	claims := clusterclaim.NewUpdater(hub)
+	go func() {
+		_ = claims.Start(ctx)
+	}()
+
+	s := NewStorage(clusterUID, nil, nil, reg, rb, true, claims)
+	in := &proxyv1alpha1.LicenseRequest{
+		Request: &proxyv1alpha1.LicenseRequestRequest{
+			Features:    []string{"kubedb"},
+			WaitTimeout: &metav1.Duration{Duration: 200 * time.Millisecond},
+		},
+	}
+	reqCtx := request.WithUser(ctx, &user.DefaultInfo{Name: "kubedb-operator"})
+	start := time.Now()
+	obj, err := s.Create(reqCtx, in, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("request returned after %s, before the wait timeout", elapsed)
+	}
+	if resp := obj.(*proxyv1alpha1.LicenseRequest).Response; resp == nil || resp.Result != proxyv1alpha1.LicenseRequestTimedOut {
+		t.Errorf("response = %+v, want %s", resp, proxyv1alpha1.LicenseRequestTimedOut)
+	}
+}
+
+func TestCreateWaitsForLicenseViaClaim(t *testing.T) {
+	iss, err := devissuer.New(devissuer.DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := trust.NewBundle(iss.CACertPEM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb := storage.NewRecordBook()
+	reg := storage.NewLicenseRegistry("", storage.MinRemainingLife, rb)
+	hub := &fakeHub{iss: iss, bundle: bundle, reg: reg, acquired: sets.New[string]()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	claims := clusterclaim.NewUpdater(hub)
+	go func() {
+		_ = claims.Start(ctx)
+	}()
+
+	s := NewStorage(clusterUID, bundle, nil, reg, rb, true, claims)
+	in := &proxyv1alpha1.LicenseRequest{
+		Request: &proxyv1alpha1.LicenseRequestRequest{
+			Features:    []string{"kubedb"},
+			WaitTimeout: &metav1.Duration{Duration: 10 * time.Second},
+		},
+	}
+	reqCtx := request.WithUser(ctx, &user.DefaultInfo{Name: "kubedb-operator"})
+	obj, err := s.Create(reqCtx, in, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.err != nil {
+		t.Fatal(hub.err)
+	}
+	if hub.claim == nil || !hub.claim.Features.Has("kubedb") {
+		t.Fatalf("feature was not added to the claim: %+v", hub.claim)
+	}
+	if _, found := hub.claim.LastRequested["kubedb"]; !found {
+		t.Error("last requested time of the feature is not tracked")
+	}
+	resp := obj.(*proxyv1alpha1.LicenseRequest).Response
+	if resp == nil || resp.Result != proxyv1alpha1.LicenseRequestFound || resp.License == "" {
+		t.Fatalf("response = %+v, want the license acquired by the hub", resp)
+	}
+}
@@ -119,8 +119,9 @@ func (r *Storage) toLicenseStatus(rec *storage.Record) proxyv1alpha1.LicenseStat
 		},
 		Spec: proxyv1alpha1.LicenseStatusSpec{},
 		Status: proxyv1alpha1.LicenseStatusStatus{
-			License:  *rec.License,
-			Contract: rec.Contract,
+			License:    *rec.License,
+			Contract:   rec.Contract,
+			VerifiedBy: rec.Anchor,
 		},
 	}
 	if spec, ok := r.rb.UsedBy(rec.License.ID); ok {
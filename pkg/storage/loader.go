@@ -21,35 +21,29 @@ import (
 	"path/filepath"
 	"time"
 
-	verifier "go.bytebuilders.dev/license-verifier"
-	"go.bytebuilders.dev/license-verifier/info"
+	"go.bytebuilders.dev/license-proxyserver/pkg/common"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog/v2"
 )
 
-func LoadDir(cid, dir string, reg *LicenseRegistry) error {
-	caData, err := info.LoadLicenseCA()
-	if err != nil {
-		return err
-	}
-	caCert, err := info.ParseCertificate(caData)
-	if err != nil {
-		return err
-	}
-
+// LoadDir adds the licenses stored in dir to the registry. Files that are not valid
+// licenses for the cluster are skipped and their errors returned by file name.
+func LoadDir(cid, dir string, bundle *trust.Bundle, reg *LicenseRegistry) (map[string]error, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return errors.Wrapf(err, "failed to read dir %s", dir)
+		return nil, errors.Wrapf(err, "failed to read dir %s", dir)
 	}
+	invalid := map[string]error{}
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() || entry.Name() == common.LicenseIndexKey {
 			continue
 		}
 
 		dirLink, err := isSymlinkToDir(dir, entry)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if dirLink {
 			continue
@@ -58,16 +52,12 @@ func LoadDir(cid, dir string, reg *LicenseRegistry) error {
 		filename := filepath.Join(dir, entry.Name())
 		data, err := os.ReadFile(filename)
 		if err != nil {
-			return errors.Wrapf(err, "failed to load file %s", filename)
+			return nil, errors.Wrapf(err, "failed to load file %s", filename)
 		}
 
-		license, err := verifier.ParseLicense(verifier.ParserOptions{
-			ClusterUID: cid,
-			CACert:     caCert,
-			License:    data,
-		})
+		license, anchor, err := bundle.ParseLicense(cid, data)
 		if err != nil {
-			klog.ErrorS(err, "Skipping", "file", filename)
+			invalid[filename] = err
 			continue
 		} else if time.Until(license.NotAfter.Time) >= MinRemainingLife {
 			klog.InfoS("adding license",
@@ -76,11 +66,12 @@ func LoadDir(cid, dir string, reg *LicenseRegistry) error {
 				"product", license.ProductLine,
 				"plan", license.PlanName,
 				"expiry", license.NotAfter.UTC().Format(time.RFC822),
+				"anchor", anchor,
 			)
-			reg.Add(&license, nil)
+			reg.Add(&license, nil, anchor)
 		}
 	}
-	return nil
+	return invalid, nil
 }
 
 func isSymlinkToDir(dir string, entry os.DirEntry) (bool, error) {
@@ -0,0 +1,64 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+	"go.bytebuilders.dev/license-proxyserver/pkg/trust"
+)
+
+const clusterUID = "8d4bd39a-a1a4-4b2b-9d6b-2f0e5b1c3e7a"
+
+func TestLoadDirReportsInvalidFiles(t *testing.T) {
+	iss, err := devissuer.New(devissuer.DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := trust.NewBundle(iss.CACertPEM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := iss.Issue(clusterUID, []string{"kubedb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "valid"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalidFile := filepath.Join(dir, "invalid")
+	if err := os.WriteFile(invalidFile, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewLicenseRegistry("", MinRemainingLife, nil)
+	invalid, err := LoadDir(clusterUID, dir, bundle, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := invalid[invalidFile]; !found || len(invalid) != 1 {
+		t.Errorf("LoadDir() invalid files = %v, want %s", invalid, invalidFile)
+	}
+	if _, found := reg.LicenseForFeature("kubedb"); !found {
+		t.Error("valid license was not loaded")
+	}
+}
@@ -17,21 +17,28 @@ limitations under the License.
 package storage
 
 import (
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	hubapi "go.bytebuilders.dev/license-proxyserver/apis/hub/v1alpha1"
 	proxyserver "go.bytebuilders.dev/license-proxyserver/apis/proxyserver/v1alpha1"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
 
 type RecordBook struct {
-	m   sync.RWMutex
-	reg map[string]*proxyserver.LicenseStatusSpec // id -> usage info
+	m     sync.RWMutex
+	reg   map[string]*proxyserver.LicenseStatusSpec // id -> usage info
+	usage map[string]map[string]time.Time           // feature -> username -> last request time
 }
 
 func NewRecordBook() *RecordBook {
 	return &RecordBook{
-		reg: make(map[string]*proxyserver.LicenseStatusSpec),
+		reg:   make(map[string]*proxyserver.LicenseStatusSpec),
+		usage: make(map[string]map[string]time.Time),
 	}
 }
 
@@ -39,6 +46,16 @@ func (r *RecordBook) Record(id string, features []string, user user.Info) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
+	now := time.Now()
+	for _, feature := range features {
+		consumers, ok := r.usage[feature]
+		if !ok {
+			consumers = make(map[string]time.Time)
+			r.usage[feature] = consumers
+		}
+		consumers[user.GetName()] = now
+	}
+
 	extra := make(map[string]proxyserver.ExtraValue)
 	for k, v := range user.GetExtra() {
 		extra[k] = v
@@ -68,3 +85,34 @@ func (r *RecordBook) Delete(id string) {
 
 	delete(r.reg, id)
 }
+
+// Usage returns the features requested since start, along with the users that requested them.
+func (r *RecordBook) Usage() []hubapi.FeatureUsage {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	out := make([]hubapi.FeatureUsage, 0, len(r.usage))
+	for feature, consumers := range r.usage {
+		fu := hubapi.FeatureUsage{
+			Feature:   feature,
+			Consumers: make([]hubapi.FeatureConsumer, 0, len(consumers)),
+		}
+		for username, t := range consumers {
+			fu.Consumers = append(fu.Consumers, hubapi.FeatureConsumer{
+				Username:        username,
+				LastRequestTime: metav1.NewTime(t),
+			})
+			if t.After(fu.LastRequestTime.Time) {
+				fu.LastRequestTime = metav1.NewTime(t)
+			}
+		}
+		slices.SortFunc(fu.Consumers, func(a, b hubapi.FeatureConsumer) int {
+			return strings.Compare(a.Username, b.Username)
+		})
+		out = append(out, fu)
+	}
+	slices.SortFunc(out, func(a, b hubapi.FeatureUsage) int {
+		return strings.Compare(a.Feature, b.Feature)
+	})
+	return out
+}
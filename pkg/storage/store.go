@@ -64,6 +64,8 @@ func (pq *LicenseQueue) Pop() any {
 type Record struct {
 	License  *v1alpha1.License
 	Contract *v1alpha1.Contract
+	// Anchor is the name of the CA that verified the license
+	Anchor string
 }
 
 type LicenseRegistry struct {
@@ -73,6 +75,7 @@ type LicenseRegistry struct {
 	rb       *RecordBook
 	cacheDir string
 	ttl      time.Duration
+	added    chan struct{} // closed when a license is added
 }
 
 func NewLicenseRegistry(cacheDir string, ttl time.Duration, rb *RecordBook) *LicenseRegistry {
@@ -82,10 +85,19 @@ func NewLicenseRegistry(cacheDir string, ttl time.Duration, rb *RecordBook) *Lic
 		reg:      make(map[string]LicenseQueue),
 		store:    make(map[string]*Record),
 		rb:       rb,
+		added:    make(chan struct{}),
 	}
 }
 
-func (r *LicenseRegistry) Add(l *v1alpha1.License, c *v1alpha1.Contract) {
+// Added returns a channel that is closed the next time a license is added to the registry.
+func (r *LicenseRegistry) Added() <-chan struct{} {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.added
+}
+
+func (r *LicenseRegistry) Add(l *v1alpha1.License, c *v1alpha1.Contract, anchor string) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -93,7 +105,7 @@ func (r *LicenseRegistry) Add(l *v1alpha1.License, c *v1alpha1.Contract) {
 		return
 	}
 
-	r.addToStore(l, c)
+	r.addToStore(l, c, anchor)
 	for _, feature := range l.Features {
 		q, ok := r.reg[feature]
 		if !ok {
@@ -106,6 +118,36 @@ func (r *LicenseRegistry) Add(l *v1alpha1.License, c *v1alpha1.Contract) {
 		heap.Push(&q, l)
 		r.reg[feature] = q
 	}
+
+	close(r.added)
+	r.added = make(chan struct{})
+}
+
+// Remove drops the license with the id from the registry, if found.
+func (r *LicenseRegistry) Remove(id string) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rec, ok := r.store[id]
+	if !ok {
+		return false
+	}
+	for _, feature := range rec.License.Features {
+		q := r.reg[feature]
+		for i, l := range q {
+			if l.ID == id {
+				heap.Remove(&q, i)
+				break
+			}
+		}
+		if q.Len() == 0 {
+			delete(r.reg, feature)
+		} else {
+			r.reg[feature] = q
+		}
+	}
+	r.removeFromStore(rec.License)
+	return true
 }
 
 func (r *LicenseRegistry) LicenseForFeature(feature string) (*v1alpha1.License, bool) {
@@ -137,8 +179,8 @@ func (r *LicenseRegistry) LicenseForFeature(feature string) (*v1alpha1.License,
 	return nil, false
 }
 
-func (r *LicenseRegistry) addToStore(l *v1alpha1.License, c *v1alpha1.Contract) {
-	r.store[l.ID] = &Record{License: l, Contract: c}
+func (r *LicenseRegistry) addToStore(l *v1alpha1.License, c *v1alpha1.Contract, anchor string) {
+	r.store[l.ID] = &Record{License: l, Contract: c, Anchor: anchor}
 	if r.cacheDir != "" {
 		_ = os.WriteFile(filepath.Join(r.cacheDir, l.ID), l.Data, 0o644)
 	}
@@ -0,0 +1,116 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trust
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	verifier "go.bytebuilders.dev/license-verifier"
+	"go.bytebuilders.dev/license-verifier/apis/licenses/v1alpha1"
+	"go.bytebuilders.dev/license-verifier/info"
+
+	"github.com/pkg/errors"
+	"gomodules.xyz/cert"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Bundle holds the CA certificates trusted to sign licenses. Multiple anchors
+// allow a license issuer to rotate its CA without a new proxy binary.
+type Bundle struct {
+	anchors []*x509.Certificate
+}
+
+// NewBundle parses one or more PEM encoded CA certificates.
+func NewBundle(data []byte) (*Bundle, error) {
+	certs, err := cert.ParseCertsPEM(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse license CA bundle")
+	}
+	return &Bundle{anchors: certs}, nil
+}
+
+// LoadBundle reads the CA bundle from file. If file is empty, the AppsCode
+// license CA is used.
+func LoadBundle(file string) (*Bundle, error) {
+	if file == "" {
+		data, err := info.LoadLicenseCA()
+		if err != nil {
+			return nil, err
+		}
+		return NewBundle(data)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read license CA file %s", file)
+	}
+	return NewBundle(data)
+}
+
+func (b *Bundle) Anchors() []*x509.Certificate {
+	return b.anchors
+}
+
+// ParseLicense verifies the license against every anchor in the bundle and
+// returns the name of the anchor that verified it. If no anchor verifies the
+// license, the error from the anchor that signed it is returned, so that
+// expired or foreign licenses are not reported as signature mismatches.
+// If no anchor signed the license, the errors of all anchors are aggregated.
+func (b *Bundle) ParseLicense(cid string, data []byte) (v1alpha1.License, string, error) {
+	if len(b.anchors) == 0 {
+		return v1alpha1.License{}, "", errors.New("no license CA configured")
+	}
+	crt, err := info.ParseCertificate(data)
+	if err != nil {
+		l, err := verifier.BadLicense(err)
+		return l, "", err
+	}
+
+	var (
+		first v1alpha1.License
+		errs  []error
+	)
+	for i, ca := range b.anchors {
+		l, err := verifier.ParseLicense(verifier.ParserOptions{
+			ClusterUID: cid,
+			CACert:     ca,
+			License:    data,
+		})
+		if err == nil {
+			return l, AnchorName(ca), nil
+		}
+		if crt.CheckSignatureFrom(ca) == nil {
+			return l, "", err
+		}
+		if i == 0 {
+			first = l
+		}
+		errs = append(errs, errors.Wrap(err, AnchorName(ca)))
+	}
+	return first, "", utilerrors.NewAggregate(errs)
+}
+
+// AnchorName identifies a CA certificate by its common name and
+// the prefix of its SHA-256 fingerprint.
+func AnchorName(ca *x509.Certificate) string {
+	sum := sha256.Sum256(ca.Raw)
+	return fmt.Sprintf("%s/sha256:%s", ca.Subject.CommonName, hex.EncodeToString(sum[:8]))
+}
@@ -0,0 +1,84 @@
+/*
+Copyright AppsCode Inc. and Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trust
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"go.bytebuilders.dev/license-proxyserver/pkg/devissuer"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const clusterUID = "8d4bd39a-a1a4-4b2b-9d6b-2f0e5b1c3e7a"
+
+func TestParseLicenseErrors(t *testing.T) {
+	other, err := devissuer.New(devissuer.DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss, err := devissuer.New(devissuer.DefaultLicenseOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the unrelated anchor comes first
+	bundle, err := NewBundle(bytes.Join([][]byte{other.CACertPEM(), iss.CACertPEM()}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foreign, _, err := iss.Issue("0f6a4d8e-5b1c-4f0a-9a57-3c2d1e0b9f84", []string{"kubedb-ext-stash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := devissuer.DefaultLicenseOptions()
+	opts.Status = devissuer.StatusInvalid
+	iss.SetLicenseOptions(opts)
+	untrusted, _, err := iss.Issue(clusterUID, []string{"kubedb-ext-stash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		license []byte
+		check   func(err error) bool
+	}{
+		{"license of another cluster", foreign, func(err error) bool {
+			var hostErr x509.HostnameError
+			return errors.As(err, &hostErr)
+		}},
+		{"untrusted signer", untrusted, func(err error) bool {
+			var agg utilerrors.Aggregate
+			return errors.As(err, &agg) && len(agg.Errors()) == 2
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _, err := bundle.ParseLicense(clusterUID, tt.license)
+			if !tt.check(err) {
+				t.Errorf("ParseLicense() error = %v", err)
+			}
+			if l.ID == "" {
+				t.Error("ParseLicense() did not return the parsed license")
+			}
+		})
+	}
+}